@@ -2,20 +2,16 @@ package lib
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 )
 
-var (
-	cacher  *Cacher
-	changes *Cacher
-)
-
 type CacheStatus int
 
 const (
@@ -25,11 +21,34 @@ const (
 	New
 )
 
+// String returns name of cache status
+func (s CacheStatus) String() string {
+	switch s {
+	case Remained:
+		return "remained"
+	case Updated:
+		return "updated"
+	case New:
+		return "new"
+	}
+
+	return "none"
+}
+
+// Change is a file differs from cache
+type Change struct {
+	Kind  string // "xlsx", "proto" or "data"
+	Name  string
+	State CacheStatus
+}
+
 // Cacher is handler for cache
 type Cacher struct {
-	XlsxInfos  map[string]*DataInfo `json:"xlsx_info"`
-	ProtoInfos map[string]*DataInfo `json:"proto_info"`
-	DataInfos  map[string]*DataInfo `json:"data_info"`
+	XlsxInfos   map[string]*DataInfo `json:"xlsx_info"`
+	ProtoInfos  map[string]*DataInfo `json:"proto_info"`
+	DataInfos   map[string]*DataInfo `json:"data_info"`
+	LoaderInfos map[string]*DataInfo `json:"loader_info,omitempty"`
+	RefInfos    map[string]*RefInfo  `json:"ref_info,omitempty"`
 
 	mutex sync.RWMutex
 }
@@ -42,16 +61,18 @@ type DataInfo struct {
 }
 
 // CacheInit initialize cacher and read from file
-func CacheInit() {
-	cacher = newCacher()
-	changes = newCacher()
-
-	if _, err := os.Stat(cfg.CacheFile); err == nil {
-		err := cacher.Load()
-		if err != nil {
-			log.Fatal("load cache fail", err)
+func (cv *Converter) CacheInit() error {
+	cv.cacher = newCacher()
+	cv.changes = newCacher()
+	cv.rowChanges = nil
+
+	if _, err := os.Stat(cv.cfg.CacheFile); err == nil {
+		if err := cv.cacher.Load(cv.cfg.CacheFile); err != nil {
+			return fmt.Errorf("load cache fail, %v", err)
 		}
 	}
+
+	return nil
 }
 
 func newCacher() *Cacher {
@@ -59,18 +80,20 @@ func newCacher() *Cacher {
 	cacher.XlsxInfos = make(map[string]*DataInfo)
 	cacher.ProtoInfos = make(map[string]*DataInfo)
 	cacher.DataInfos = make(map[string]*DataInfo)
+	cacher.LoaderInfos = make(map[string]*DataInfo)
+	cacher.RefInfos = make(map[string]*RefInfo)
 
 	return cacher
 }
 
 // Load read data from json cache file
-func (c *Cacher) Load() error {
-	rawData, err := ioutil.ReadFile(cfg.CacheFile)
+func (c *Cacher) Load(cacheFile string) error {
+	rawData, err := ioutil.ReadFile(cacheFile)
 	if err != nil {
 		return err
 	}
 
-	err = json.Unmarshal(rawData, cacher)
+	err = json.Unmarshal(rawData, c)
 	if err != nil {
 		return err
 	}
@@ -78,17 +101,19 @@ func (c *Cacher) Load() error {
 	return nil
 }
 
-// Save write current data to cache file as json
-func (c *Cacher) Save() error {
+// SaveCache write current cache to cache file as json, and copy changed files to change output path
+func (cv *Converter) SaveCache() error {
+	c, changes, cfg := cv.cacher, cv.changes, cv.cfg
+
 	// remove previous
 	if err := os.RemoveAll(cfg.ChangeOutputPath); err != nil {
-		log.Fatal(err)
+		return err
 	}
 	if err := os.MkdirAll(cfg.ChangeOutputPath+"/proto", 0777); err != nil {
-		log.Fatal(err)
+		return err
 	}
 	if err := os.MkdirAll(cfg.ChangeOutputPath+"/data", 0777); err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	// clear files not appears in current time
@@ -103,7 +128,7 @@ func (c *Cacher) Save() error {
 		switch info.State {
 		case Updated, New:
 			changes.ProtoInfos[pName] = info
-			if err := CopyChangedProtoFiles(pName); err != nil {
+			if err := cv.CopyChangedProtoFiles(pName); err != nil {
 				return err
 			}
 		}
@@ -112,21 +137,23 @@ func (c *Cacher) Save() error {
 	for dName, info := range c.DataInfos {
 		switch info.State {
 		case Updated, New:
-			changes.ProtoInfos[dName] = info
-			if err := CopyChangedDataFiles(dName); err != nil {
+			changes.DataInfos[dName] = info
+			if err := cv.CopyChangedDataFiles(dName); err != nil {
 				return err
 			}
 		}
 	}
 
-	rawData, err := json.MarshalIndent(cacher, "", "    ")
+	c.RefInfos = cv.refInfos
+
+	rawData, err := json.MarshalIndent(c, "", "    ")
 	if err != nil {
 		return err
 	}
 
 	CacheDir := filepath.Dir(cfg.CacheFile)
 	if err := os.MkdirAll(CacheDir, 0777); err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	err = ioutil.WriteFile(cfg.CacheFile, rawData, 0644)
@@ -144,52 +171,171 @@ func (c *Cacher) Save() error {
 		return err
 	}
 
-	return nil
+	return cv.writeRowChanges()
 }
 
 // ClearCache remove saved records and initialize a new cacher
-func ClearCache() {
-	if _, err := os.Stat(cfg.CacheFile); err == nil {
-		if err := os.Remove(cfg.CacheFile); err != nil {
-			log.Fatal("remove cache failed, ", err)
-			return
+func (cv *Converter) ClearCache() error {
+	if _, err := os.Stat(cv.cfg.CacheFile); err == nil {
+		if err := os.Remove(cv.cfg.CacheFile); err != nil {
+			return fmt.Errorf("remove cache failed, %v", err)
 		}
 	}
 
-	cacher = newCacher()
+	cv.cacher = newCacher()
+
+	return nil
 }
 
-// CopyChangedProtoFiles if a proto file is changed, copy both proto and data file to output dir
-func CopyChangedProtoFiles(fName string) error {
-	fn := strings.ToLower(fName)
+// Changes returns files differ from cache in current run
+func (cv *Converter) Changes() []*Change {
+	var changes []*Change
+	if cv.cacher == nil {
+		return changes
+	}
 
-	srcProtoFile := filepath.Join(cfg.ProtoOutPath, fn+cfg.ProtoOutExt)
-	_, err := os.Stat(srcProtoFile)
-	if err != nil {
-		return err
+	cv.cacher.mutex.RLock()
+	defer cv.cacher.mutex.RUnlock()
+
+	for _, kind := range []struct {
+		name  string
+		infos map[string]*DataInfo
+	}{
+		{"xlsx", cv.cacher.XlsxInfos},
+		{"proto", cv.cacher.ProtoInfos},
+		{"data", cv.cacher.DataInfos},
+	} {
+		var names []string
+		for name, info := range kind.infos {
+			if info.State == Updated || info.State == New {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			changes = append(changes, &Change{Kind: kind.name, Name: name, State: kind.infos[name].State})
+		}
 	}
 
-	srcProto, err := os.Open(srcProtoFile)
-	if err != nil {
-		return err
+	return changes
+}
+
+// Clean remove generated proto, descriptor, data, json, lua, text and loader files and the cache
+// field lock file is kept, so field numbers will not change after clean
+func (cv *Converter) Clean() error {
+	cfg := cv.cfg
+	var patterns []string
+	for _, dir := range append([]string{""}, cfg.Targets...) {
+		patterns = append(patterns,
+			filepath.Join(cfg.ProtoOutPath, dir, "*"+cfg.ProtoOutExt),
+			filepath.Join(cfg.ProtoOutPath, dir, "*"+DescriptorExt),
+			filepath.Join(cfg.DataOutPath, dir, "*"+cfg.DataOutExt),
+			filepath.Join(cfg.DataOutPath, dir, "*"+TextExt),
+		)
+		if cfg.JSONOutPath != "" {
+			patterns = append(patterns, filepath.Join(cfg.JSONOutPath, dir, "*"+cfg.JSONOutExt))
+		}
+		if cfg.GoOutPath != "" {
+			patterns = append(patterns, filepath.Join(cfg.GoOutPath, dir, "*"+GoLoaderExt))
+		}
+		if cfg.LuaOutPath != "" {
+			patterns = append(patterns, filepath.Join(cfg.LuaOutPath, dir, "*"+LuaExt))
+		}
+		if cfg.CSharpOutPath != "" {
+			patterns = append(patterns, filepath.Join(cfg.CSharpOutPath, dir, "*"+CSharpExt), filepath.Join(cfg.CSharpOutPath, dir, CSharpReaderFile))
+		}
 	}
-	defer srcProto.Close()
 
-	dstProtoFile := filepath.Join(cfg.ChangeOutputPath, "proto", fn+cfg.ProtoOutExt)
-	dstProto, err := os.Create(dstProtoFile)
-	if err != nil {
-		return err
+	for _, pattern := range patterns {
+		// never remove all files of a dir if an ext is empty
+		if strings.HasSuffix(pattern, "*") {
+			continue
+		}
+
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := os.Remove(file); err != nil {
+				return err
+			}
+		}
 	}
-	defer dstProto.Close()
 
-	_, err = io.Copy(dstProto, srcProto)
+	return cv.ClearCache()
+}
 
-	return err
+// ForgetXlsx remove the record of a xlsx file, so it will be handled again next time
+func (cv *Converter) ForgetXlsx(filename string) {
+	if cv.cacher == nil {
+		return
+	}
+
+	cv.cacher.mutex.Lock()
+	defer cv.cacher.mutex.Unlock()
+
+	for _, fn := range strings.Split(filename, "|") {
+		delete(cv.cacher.XlsxInfos, fn)
+	}
+}
+
+// forgetOutput remove proto, data and loader hashes of a sheet, so its files will be written again next time
+// hashes are saved before files are written, they are wrong if any file failed to write
+func (cv *Converter) forgetOutput(pr *ProtoSheet) {
+	if cv.cacher == nil {
+		return
+	}
+
+	cv.cacher.mutex.Lock()
+	defer cv.cacher.mutex.Unlock()
+
+	delete(cv.cacher.ProtoInfos, pr.outputName())
+	delete(cv.cacher.DataInfos, pr.outputName())
+	delete(cv.cacher.LoaderInfos, pr.outputName())
+}
+
+// CopyChangedProtoFiles if a proto file is changed, copy both proto and descriptor file to output dir
+func (cv *Converter) CopyChangedProtoFiles(fName string) error {
+	fn := strings.ToLower(fName)
+	cfg := cv.cfg
+
+	for _, ext := range []string{cfg.ProtoOutExt, DescriptorExt} {
+		srcProtoFile := filepath.Join(cfg.ProtoOutPath, fn+ext)
+		_, err := os.Stat(srcProtoFile)
+		if err != nil {
+			return err
+		}
+
+		srcProto, err := os.Open(srcProtoFile)
+		if err != nil {
+			return err
+		}
+		defer srcProto.Close()
+
+		dstProtoFile := filepath.Join(cfg.ChangeOutputPath, "proto", fn+ext)
+		if err := os.MkdirAll(filepath.Dir(dstProtoFile), 0777); err != nil { // file of a target is in sub dir
+			return err
+		}
+		dstProto, err := os.Create(dstProtoFile)
+		if err != nil {
+			return err
+		}
+		defer dstProto.Close()
+
+		if _, err = io.Copy(dstProto, srcProto); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // CopyChangedDataFiles if a data file is changed, copy data file to output dir
-func CopyChangedDataFiles(fName string) error {
+func (cv *Converter) CopyChangedDataFiles(fName string) error {
 	fn := strings.ToLower(fName)
+	cfg := cv.cfg
 
 	srcDataFile := filepath.Join(cfg.DataOutPath, fn+cfg.DataOutExt)
 	_, err := os.Stat(srcDataFile)
@@ -204,6 +350,9 @@ func CopyChangedDataFiles(fName string) error {
 	defer srcData.Close()
 
 	dstDataFile := filepath.Join(cfg.ChangeOutputPath, "data", fn+cfg.DataOutExt)
+	if err := os.MkdirAll(filepath.Dir(dstDataFile), 0777); err != nil {
+		return err
+	}
 	dstData, err := os.Create(dstDataFile)
 	if err != nil {
 		return err
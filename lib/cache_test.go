@@ -5,11 +5,10 @@ import (
 )
 
 var (
-	testCacher       *Cacher
-	preCacheFileName string
+	testCacher *Cacher
 )
 
-func setup() {
+func setup() *Converter {
 	testCacher = newCacher()
 
 	info := new(DataInfo)
@@ -18,33 +17,30 @@ func setup() {
 
 	testCacher.XlsxInfos[info.Name] = info
 
-	// mock filename
-	preCacheFileName = cfg.CacheFile
-	cfg.CacheFile = "../cache/sheetcachetest.json"
+	return newConverter(newTestConfig())
 }
 
-func tearDown() {
-	os.Remove(cfg.CacheFile)
-	cfg.CacheFile = preCacheFileName
+func tearDown(cv *Converter) {
+	os.Remove(cv.cfg.CacheFile)
 }
 
 /*func TestCacheReadAndWrite(t *testing.T) {
-	setup()
+	cv := setup()
 
 	// Save
-	cacher = testCacher
-	assert.NoError(t, cacher.Save())
+	cv.cacher = testCacher
+	assert.NoError(t, cv.SaveCache())
 
 	// Load
-	cacher = newCacher()
-	assert.NoError(t, cacher.Load())
-	assert.Equal(t, testCacher, cacher)
+	cv.cacher = newCacher()
+	assert.NoError(t, cv.cacher.Load(cv.cfg.CacheFile))
+	assert.Equal(t, testCacher, cv.cacher)
 
 	// Clear
-	ClearCache()
-	assert.Equal(t, newCacher(), cacher)
-	_, err := os.Stat(cfg.CacheFile)
+	assert.NoError(t, cv.ClearCache())
+	assert.Equal(t, newCacher(), cv.cacher)
+	_, err := os.Stat(cv.cfg.CacheFile)
 	assert.Error(t, err)
 
-	tearDown()
+	tearDown(cv)
 }*/
@@ -0,0 +1,275 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultRowChangeLog is saved next to change log if row change log is not set in config
+const DefaultRowChangeLog = "rowchanges.json"
+
+// States of a changed row
+const (
+	RowAdded    = "added"
+	RowRemoved  = "removed"
+	RowModified = "modified"
+)
+
+// SheetChange contains rows of a sheet changed since last run
+type SheetChange struct {
+	Sheet string       `json:"sheet"`
+	Key   string       `json:"key"` // fields to match rows, the first unique column, the first composite key or the first column
+	Rows  []*RowChange `json:"rows"`
+}
+
+// RowChange is a row added, removed or modified
+type RowChange struct {
+	Key    string         `json:"key"`
+	State  string         `json:"state"`
+	Fields []*FieldChange `json:"fields"` // changed fields, all fields of added or removed rows
+}
+
+// FieldChange is the old and new value of a field, nil if the field is not set
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+}
+
+// rowChangeLog returns path of row change log in json
+func (c *Config) rowChangeLog() string {
+	if c.RowChangeLog == "" {
+		return filepath.Join(filepath.Dir(c.ChangeLog), DefaultRowChangeLog)
+	}
+
+	return c.RowChangeLog
+}
+
+// rowKeySep separates fields of a composite key in row keys, e.g. "1,2" for ChapterID and StageID
+const rowKeySep = ","
+
+// keyFields returns names of fields to match rows between runs
+func (pr *ProtoSheet) keyFields() []string {
+	for _, val := range pr.vars {
+		if val.proto2Type == Unique {
+			return []string{val.name}
+		}
+	}
+
+	if keys := groupUniqueKeys(pr.vars); len(keys) > 0 {
+		names := make([]string, 0, len(keys[0].vals))
+		for _, val := range keys[0].vals {
+			names = append(names, val.name)
+		}
+		return names
+	}
+
+	fields := pr.arrayDesc.Fields().ByNumber(1).Message().Fields()
+	if fields.Len() == 0 {
+		return nil
+	}
+
+	return []string{string(fields.Get(0).Name())}
+}
+
+// diffRows compare current data with files written by last run, returns nil if no row changed
+func (pr *ProtoSheet) diffRows() (*SheetChange, error) {
+	cur, err := pr.decodeItems()
+	if err != nil {
+		return nil, err
+	}
+
+	var prev []jsonObject
+	array, err := loadArray(pr.cfg, pr.Name)
+	if err == nil {
+		prev = decodeArray(array)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	keyFields := pr.keyFields()
+	change := &SheetChange{Sheet: pr.outputName(), Key: strings.Join(keyFields, rowKeySep)}
+	prevKeys, prevRows := keyRows(prev, keyFields)
+	curKeys, curRows := keyRows(cur, keyFields)
+
+	for _, key := range curKeys {
+		old, ok := prevRows[key]
+		if !ok {
+			change.Rows = append(change.Rows, &RowChange{Key: key, State: RowAdded, Fields: diffFields(nil, curRows[key])})
+			continue
+		}
+		if fields := diffFields(old, curRows[key]); len(fields) > 0 {
+			change.Rows = append(change.Rows, &RowChange{Key: key, State: RowModified, Fields: fields})
+		}
+	}
+	for _, key := range prevKeys {
+		if _, ok := curRows[key]; !ok {
+			change.Rows = append(change.Rows, &RowChange{Key: key, State: RowRemoved, Fields: diffFields(prevRows[key], nil)})
+		}
+	}
+
+	if len(change.Rows) == 0 {
+		return nil, nil
+	}
+
+	return change, nil
+}
+
+// keyRows index rows by values of key fields, duplicated keys are suffixed with "#2", "#3" and so on
+func keyRows(items []jsonObject, keyFields []string) ([]string, map[string]jsonObject) {
+	keys := make([]string, 0, len(items))
+	rows := make(map[string]jsonObject, len(items))
+	for _, item := range items {
+		values := make([]string, len(keyFields))
+		for i, name := range keyFields {
+			for _, field := range item {
+				if field.name == name {
+					values[i] = formatValue(field.value)
+					break
+				}
+			}
+		}
+		base := strings.Join(values, rowKeySep)
+
+		key := base
+		for n := 2; ; n++ {
+			if _, ok := rows[key]; !ok {
+				break
+			}
+			key = fmt.Sprintf("%s#%d", base, n)
+		}
+
+		keys = append(keys, key)
+		rows[key] = item
+	}
+
+	return keys, rows
+}
+
+// diffFields returns fields differ between two rows, in the order of current proto then removed fields
+func diffFields(old, cur jsonObject) []*FieldChange {
+	oldValues := make(map[string]interface{}, len(old))
+	for _, field := range old {
+		oldValues[field.name] = field.value
+	}
+
+	var changes []*FieldChange
+	seen := make(map[string]struct{}, len(cur))
+	for _, field := range cur {
+		seen[field.name] = struct{}{}
+		if oldValue, ok := oldValues[field.name]; !ok || !equalValue(oldValue, field.value) {
+			changes = append(changes, &FieldChange{Field: field.name, Old: oldValues[field.name], New: field.value})
+		}
+	}
+	for _, field := range old {
+		if _, ok := seen[field.name]; !ok {
+			changes = append(changes, &FieldChange{Field: field.name, Old: field.value})
+		}
+	}
+
+	return changes
+}
+
+func equalValue(a, b interface{}) bool {
+	rawA, errA := json.Marshal(a)
+	rawB, errB := json.Marshal(b)
+
+	return errA == nil && errB == nil && string(rawA) == string(rawB)
+}
+
+// formatValue convert a decoded value to text, strings are not quoted
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(raw)
+}
+
+func (cv *Converter) addRowChange(change *SheetChange) {
+	if change == nil {
+		return
+	}
+
+	cv.mutex.Lock()
+	defer cv.mutex.Unlock()
+
+	cv.rowChanges = append(cv.rowChanges, change)
+}
+
+// writeRowChanges output rows changed in current run as json and markdown
+func (cv *Converter) writeRowChanges() error {
+	changes := cv.rowChanges
+	if changes == nil {
+		changes = []*SheetChange{}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Sheet < changes[j].Sheet
+	})
+
+	jsonFile := cv.cfg.rowChangeLog()
+	if err := os.MkdirAll(filepath.Dir(jsonFile), 0777); err != nil {
+		return err
+	}
+
+	rawData, err := json.MarshalIndent(changes, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(jsonFile, rawData, 0644); err != nil {
+		return err
+	}
+
+	mdFile := strings.TrimSuffix(jsonFile, filepath.Ext(jsonFile)) + ".md"
+
+	return ioutil.WriteFile(mdFile, []byte(markdownChanges(changes)), 0644)
+}
+
+// markdownChanges render changed rows as tables, one table for each sheet
+// only keys of added and removed rows are listed
+func markdownChanges(changes []*SheetChange) string {
+	var sb strings.Builder
+
+	sb.WriteString("# Data changes\n")
+	if len(changes) == 0 {
+		sb.WriteString("\nNo row changed.\n")
+	}
+
+	for _, change := range changes {
+		fmt.Fprintf(&sb, "\n## %s\n\n", change.Sheet)
+		fmt.Fprintf(&sb, "| %s | State | Field | Old | New |\n", markdownCell(change.Key))
+		sb.WriteString("| --- | --- | --- | --- | --- |\n")
+		for _, row := range change.Rows {
+			if row.State != RowModified {
+				fmt.Fprintf(&sb, "| %s | %s | | | |\n", markdownCell(row.Key), row.State)
+				continue
+			}
+			for _, field := range row.Fields {
+				fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s |\n", markdownCell(row.Key), row.State,
+					markdownCell(field.Field), markdownCell(formatValue(field.Old)), markdownCell(formatValue(field.New)))
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// markdownCell escape text to be put in a table cell
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
@@ -0,0 +1,149 @@
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffFields(t *testing.T) {
+	old := jsonObject{{"ID", 1}, {"Name", "a"}, {"Tags", []interface{}{"x"}}, {"Removed", 3}}
+	cur := jsonObject{{"ID", 1}, {"Name", "b"}, {"Tags", []interface{}{"x"}}, {"Rate", 0.5}}
+
+	raw, err := json.Marshal(diffFields(old, cur))
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"field":"Name","old":"a","new":"b"},{"field":"Rate","new":0.5},{"field":"Removed","old":3}]`, string(raw))
+	assert.Empty(t, diffFields(cur, cur))
+}
+
+func TestKeyRows(t *testing.T) {
+	keys, rows := keyRows([]jsonObject{
+		{{"ID", 1}, {"Name", "a"}},
+		{{"ID", 1}, {"Name", "b"}},
+		{{"Name", "c"}},
+	}, []string{"ID"})
+	assert.Equal(t, []string{"1", "1#2", ""}, keys)
+	assert.Equal(t, "b", rows["1#2"][1].value)
+
+	keys, _ = keyRows([]jsonObject{
+		{{"ChapterID", 1}, {"StageID", 1}},
+		{{"ChapterID", 1}, {"StageID", 2}},
+	}, []string{"ChapterID", "StageID"})
+	assert.Equal(t, []string{"1,1", "1,2"}, keys)
+}
+
+func TestRowChanges(t *testing.T) {
+	cfg := newTempConfig(t)
+	cfg.XlsxPath = t.TempDir()
+	assert.NoError(t, cfg.CheckDirs())
+
+	csvFile := filepath.Join(cfg.XlsxPath, "items.csv")
+	run := func(content string) {
+		assert.NoError(t, ioutil.WriteFile(csvFile, []byte(content), 0644))
+		cv := newConverter(cfg)
+		assert.NoError(t, cv.readCfgLine("ITEM items.csv"))
+		_, err := cv.Run(Options{UseCache: true})
+		assert.NoError(t, err)
+	}
+
+	run(`unique,optional,optional
+uint32,string,float
+ItemID,Name,DropRate
+ID,,
+1001,sword,0.5
+1002,shield,0.1
+`)
+	raw, err := ioutil.ReadFile(filepath.Join(cfg.ChangeOutputPath, DefaultRowChangeLog))
+	assert.NoError(t, err)
+	var changes []*SheetChange
+	assert.NoError(t, json.Unmarshal(raw, &changes))
+	assert.Len(t, changes, 1)
+	assert.Len(t, changes[0].Rows, 2)
+	assert.Equal(t, RowAdded, changes[0].Rows[0].State)
+
+	run(`unique,optional,optional
+uint32,string,float
+ItemID,Name,DropRate
+ID,,
+1001,sword|long,0.5
+1003,bow,
+`)
+	raw, err = ioutil.ReadFile(filepath.Join(cfg.ChangeOutputPath, DefaultRowChangeLog))
+	assert.NoError(t, err)
+	changes = nil
+	assert.NoError(t, json.Unmarshal(raw, &changes))
+	assert.Len(t, changes, 1)
+	assert.Equal(t, "ITEM", changes[0].Sheet)
+	assert.Equal(t, "ItemID", changes[0].Key)
+
+	rows, err := json.Marshal(changes[0].Rows)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"key":"1001","state":"modified","fields":[{"field":"Name","old":"sword","new":"sword|long"}]},`+
+		`{"key":"1003","state":"added","fields":[{"field":"ItemID","new":1003},{"field":"Name","new":"bow"}]},`+
+		`{"key":"1002","state":"removed","fields":[{"field":"ItemID","old":1002},{"field":"Name","old":"shield"},{"field":"DropRate","old":0.1}]}]`, string(rows))
+
+	md, err := ioutil.ReadFile(filepath.Join(cfg.ChangeOutputPath, "rowchanges.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, `# Data changes
+
+## ITEM
+
+| ItemID | State | Field | Old | New |
+| --- | --- | --- | --- | --- |
+| 1001 | modified | Name | sword | sword\|long |
+| 1003 | added | | | |
+| 1002 | removed | | | |
+`, string(md))
+
+	// data changes are listed as data in change log
+	raw, err = ioutil.ReadFile(cfg.ChangeLog)
+	assert.NoError(t, err)
+	cacher := newCacher()
+	assert.NoError(t, json.Unmarshal(raw, cacher))
+	assert.Contains(t, cacher.DataInfos, "ITEM")
+	assert.NotContains(t, cacher.ProtoInfos, "ITEM")
+}
+
+func TestRowChangesCompositeKey(t *testing.T) {
+	cfg := newTempConfig(t)
+	cfg.XlsxPath = t.TempDir()
+	assert.NoError(t, cfg.CheckDirs())
+
+	csvFile := filepath.Join(cfg.XlsxPath, "stages.csv")
+	for _, content := range []string{`unique:key1,unique:key1,optional
+uint32,uint32,string
+ChapterID,StageID,Name
+,,
+1,1,a
+1,2,b
+`, `unique:key1,unique:key1,optional
+uint32,uint32,string
+ChapterID,StageID,Name
+,,
+1,2,b
+1,3,c
+`} {
+		assert.NoError(t, ioutil.WriteFile(csvFile, []byte(content), 0644))
+		cv := newConverter(cfg)
+		assert.NoError(t, cv.readCfgLine("STAGE stages.csv"))
+		_, err := cv.Run(Options{UseCache: true})
+		assert.NoError(t, err)
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Join(cfg.ChangeOutputPath, DefaultRowChangeLog))
+	assert.NoError(t, err)
+	var changes []*SheetChange
+	assert.NoError(t, json.Unmarshal(raw, &changes))
+	assert.Len(t, changes, 1)
+	assert.Equal(t, "ChapterID,StageID", changes[0].Key)
+
+	// stage 1-2 is not changed
+	assert.Len(t, changes[0].Rows, 2)
+	assert.Equal(t, "1,3", changes[0].Rows[0].Key)
+	assert.Equal(t, RowAdded, changes[0].Rows[0].State)
+	assert.Equal(t, "1,1", changes[0].Rows[1].Key)
+	assert.Equal(t, RowRemoved, changes[0].Rows[1].State)
+}
@@ -0,0 +1,116 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ErrIncompatible is returned in strict compat mode if new proto breaks clients using the old one
+var ErrIncompatible = errors.New("incompatible proto change")
+
+// checkCompat compare new proto with the one written by last run
+// breaking changes are warned, or returned as error in strict mode
+func (pr *ProtoSheet) checkCompat(strict bool) error {
+	prev, err := readDescriptor(filepath.Join(pr.cfg.ProtoOutPath, strings.ToLower(pr.Name)+DescriptorExt))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read previous descriptor of %s fail, %v", pr.Name, err)
+	}
+
+	issues := compatIssues(prev, pr.fileDesc)
+	if len(issues) == 0 {
+		return nil
+	}
+
+	if strict {
+		return fmt.Errorf("%w, %s", ErrIncompatible, strings.Join(issues, "; "))
+	}
+	for _, issue := range issues {
+		log.Printf("<Warning> %s %v, %s\n", pr.Name, ErrIncompatible, issue)
+	}
+
+	return nil
+}
+
+// compatIssues returns breaking changes of messages exist in both descriptors
+func compatIssues(prev, cur *descriptorpb.FileDescriptorProto) []string {
+	prevMsgs := make(map[string]*descriptorpb.DescriptorProto)
+	for _, msg := range prev.MessageType {
+		collectMessages(prevMsgs, "", msg)
+	}
+	curMsgs := make(map[string]*descriptorpb.DescriptorProto)
+	for _, msg := range cur.MessageType {
+		collectMessages(curMsgs, "", msg)
+	}
+
+	names := make([]string, 0, len(curMsgs))
+	for name := range curMsgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var issues []string
+	for _, name := range names {
+		prevMsg, ok := prevMsgs[name]
+		if !ok {
+			continue
+		}
+
+		prevFields := make(map[int32]*descriptorpb.FieldDescriptorProto, len(prevMsg.Field))
+		for _, field := range prevMsg.Field {
+			prevFields[field.GetNumber()] = field
+		}
+
+		for _, field := range curMsgs[name].Field {
+			prevField, ok := prevFields[field.GetNumber()]
+			if !ok {
+				if field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED {
+					issues = append(issues, fmt.Sprintf("required field %s.%s added", name, field.GetName()))
+				}
+				continue
+			}
+
+			if prevField.GetName() != field.GetName() {
+				issues = append(issues, fmt.Sprintf("field %s.%s = %d renamed from %s", name, field.GetName(), field.GetNumber(), prevField.GetName()))
+			}
+			if fieldType(prevField) != fieldType(field) {
+				issues = append(issues, fmt.Sprintf("field %s.%s = %d type changed from %s to %s", name, field.GetName(), field.GetNumber(), fieldType(prevField), fieldType(field)))
+			}
+			if prevField.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED && field.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+				issues = append(issues, fmt.Sprintf("field %s.%s = %d changed from repeated to singular", name, field.GetName(), field.GetNumber()))
+			}
+			if prevField.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL && field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED {
+				issues = append(issues, fmt.Sprintf("field %s.%s = %d changed from optional to required", name, field.GetName(), field.GetNumber()))
+			}
+		}
+	}
+
+	return issues
+}
+
+// collectMessages add message and its nested messages to map[fullName]message
+func collectMessages(msgs map[string]*descriptorpb.DescriptorProto, prefix string, msg *descriptorpb.DescriptorProto) {
+	name := prefix + msg.GetName()
+	msgs[name] = msg
+	for _, nested := range msg.NestedType {
+		collectMessages(msgs, name+".", nested)
+	}
+}
+
+// fieldType returns type of field for messages, e.g. "uint32" or ".ProtobufGen.SAMPLEONE.StructName"
+func fieldType(field *descriptorpb.FieldDescriptorProto) string {
+	if field.TypeName != nil {
+		return field.GetTypeName()
+	}
+
+	return strings.TrimPrefix(strings.ToLower(field.GetType().String()), "type_")
+}
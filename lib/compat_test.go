@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompatIssues(t *testing.T) {
+	prev := genTestProtoRow()
+	assert.NoError(t, prev.BuildDescriptor())
+
+	pr := genTestProtoRow()
+	assert.NoError(t, pr.BuildDescriptor())
+	assert.Empty(t, compatIssues(prev.fileDesc, pr.fileDesc))
+
+	pr.vars[0].typ = "int32" // type changed
+	pr.vars[0].defaultValueStr = ""
+	pr.repeats[0].val.name = "TestRepeat2"      // renamed
+	pr.optStructs[0].fields[0].proto2Type = Req // optional to required
+	pr.vars = append(pr.vars, &Val{
+		CommonInfo: CommonInfo{name: "TestField5", fieldNum: 5},
+		proto2Type: Req,
+		typ:        "uint32",
+	})
+	assert.NoError(t, pr.BuildDescriptor())
+
+	assert.Equal(t, []string{
+		"field TestProtoRow.TestField1 = 1 type changed from string to int32",
+		"field TestProtoRow.TestField1 = 1 changed from optional to required",
+		"required field TestProtoRow.TestField5 added",
+		"field TestProtoRow.TestRepeat2 = 2 renamed from TestRepeat1",
+		"field TestProtoRow.TestOptStruct.TestField1 = 1 type changed from string to int32",
+		"field TestProtoRow.TestOptStruct.TestField1 = 1 changed from optional to required",
+	}, compatIssues(prev.fileDesc, pr.fileDesc))
+
+	// repeated to singular
+	pr = genTestProtoRow()
+	pr.repeats = pr.repeats[1:]
+	pr.vars = append(pr.vars, &Val{
+		CommonInfo: CommonInfo{name: "TestRepeat1", fieldNum: 2},
+		proto2Type: Opt,
+		typ:        "int64",
+	})
+	assert.NoError(t, pr.BuildDescriptor())
+	assert.Equal(t, []string{"field TestProtoRow.TestRepeat1 = 2 changed from repeated to singular"}, compatIssues(prev.fileDesc, pr.fileDesc))
+}
+
+func TestCheckCompat(t *testing.T) {
+	prev := genTestProtoRow()
+	assert.NoError(t, prev.BuildDescriptor())
+
+	pr := genTestProtoRow()
+	pr.vars[0].typ = "bool"
+	pr.vars[0].defaultValueStr = ""
+	assert.NoError(t, pr.BuildDescriptor())
+
+	// nothing to compare with
+	assert.NoError(t, pr.checkCompat(true))
+
+	assert.NoError(t, prev.WriteDescriptor())
+	defer os.Remove("../test/testprotorow" + DescriptorExt)
+
+	assert.NoError(t, pr.checkCompat(false))
+	err := pr.checkCompat(true)
+	assert.True(t, errors.Is(err, ErrIncompatible))
+	assert.Contains(t, err.Error(), "type changed from string to bool")
+}
@@ -3,7 +3,6 @@ package lib
 import (
 	"bufio"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,65 +10,93 @@ import (
 	"github.com/BurntSushi/toml"
 )
 
+// DefaultConfigFile is used when no config file is specified
+const DefaultConfigFile = "./conf/config.toml"
+
 type CConfig struct {
 	Config Config `toml:"Config"`
 }
 
 type Config struct {
-	ConfigRegExp     string `toml:"config_reg_exp"`
-	XlsxPath         string `toml:"xlsx_path"`
-	XlsxExt          string `toml:"xlsx_ext"`
-	PackageName      string `toml:"package_name"`
-	UseProto3        bool   `toml:"use_proto3"`
-	ProtoOutPath     string `toml:"proto_path"`
-	ProtoOutExt      string `toml:"proto_ext"`
-	DataOutPath      string `toml:"data_path"`
-	DataOutExt       string `toml:"data_ext"`
-	CacheFile        string `toml:"cache_file"`
-	ChangeOutputPath string `toml:"change_output_path"`
-	ChangeLog        string `toml:"change_log"`
+	ConfigRegExp     string   `toml:"config_reg_exp"`
+	XlsxPath         string   `toml:"xlsx_path"`
+	XlsxExt          string   `toml:"xlsx_ext"`
+	PackageName      string   `toml:"package_name"`
+	UseProto3        bool     `toml:"use_proto3"`
+	ProtoOutPath     string   `toml:"proto_path"`
+	ProtoOutExt      string   `toml:"proto_ext"`
+	DataOutPath      string   `toml:"data_path"`
+	DataOutExt       string   `toml:"data_ext"`
+	JSONOutPath      string   `toml:"json_path"`     // json output is disabled if empty
+	JSONOutExt       string   `toml:"json_ext"`      // ".json" or ".jsonl" for json lines
+	TextDump         bool     `toml:"text_dump"`     // also write data in protobuf text format as *.txtpb next to data files
+	Targets          []string `toml:"targets"`       // export targets such as "client" and "server", each outputs to a sub dir
+	StreamSheets     []string `toml:"stream_sheets"` // very large sheets read row by row and written to data files directly, can not be used with targets, json, lua, text dump or row change log
+	GoOutPath        string   `toml:"go_path"`       // go loader output is disabled if empty
+	GoPackage        string   `toml:"go_package"`    // go_package option of proto files, also decides package name of go loaders
+	CSharpOutPath    string   `toml:"csharp_path"`   // C# loader output is disabled if empty, package name is used as namespace
+	LuaOutPath       string   `toml:"lua_path"`      // lua output is disabled if empty
+	CacheFile        string   `toml:"cache_file"`
+	LockFile         string   `toml:"lock_file"` // field number lock file, next to cache file if empty
+	ChangeOutputPath string   `toml:"change_output_path"`
+	ChangeLog        string   `toml:"change_log"`
+	RowChangeLog     string   `toml:"row_change_log"` // rows changed in json, and in markdown with ".md" ext, next to change log if empty
 }
 
-var (
-	cfg          *Config             // config reading from <package>/conf/config.toml
-	sheetNames   map[string]struct{} // check if duplicate sheet name exists
-	sheetFileMap map[string][]string // map[filename][]sheetnames, e.g. ["Sheet1", "Sheet2, Sheet3"]
-	// fileHashMap  map[string][16]byte // map[filename]MD5
-)
+// LoadConfig read config from a toml file
+func LoadConfig(cfgFile string) (Config, error) {
+	/*	_, filename, _, ok := runtime.Caller(0)
+		if !ok {
+			panic("No caller information!")
+		}
 
-func init() {
-	cfg = new(Config)
-	cfg.LoadConfig()
-	cfg.ReplaceRelPaths()
+		cfgFile := filepath.Join(path.Dir(filename), "../conf/config.toml")*/
+	if _, err := os.Stat(cfgFile); os.IsNotExist(err) {
+		return Config{}, fmt.Errorf("config file %s does not exist", cfgFile)
+	}
 
-	ResetConfigCache()
-	for _, cfg := range getConfigFiles(cfg.XlsxPath) {
-		fmt.Printf("Config file %s found\n", cfg)
-		readCfgFile(cfg)
+	ccfg := new(CConfig)
+	if _, err := toml.DecodeFile(cfgFile, ccfg); err != nil {
+		return Config{}, err
 	}
+
+	return ccfg.Config, nil
 }
 
 // ResetConfigCache clear current config data
-func ResetConfigCache() {
-	sheetNames = make(map[string]struct{})
-	sheetFileMap = make(map[string][]string)
-	// fileHashMap = make(map[string][16]byte)
+func (cv *Converter) ResetConfigCache() {
+	cv.sheetNames = make(map[string]struct{})
+	cv.sheetFileMap = make(map[string][]string)
+	cv.enumFileMap = make(map[string][]string)
 }
 
-func getConfigFiles(tarPath string) []string {
-	files, err := filepath.Glob(filepath.Join(tarPath, cfg.ConfigRegExp))
+// LoadSheetConfigs read all xlsx*.config files in xlsx path
+func (cv *Converter) LoadSheetConfigs() error {
+	cv.ResetConfigCache()
+
+	files, err := getConfigFiles(cv.cfg.XlsxPath, cv.cfg.ConfigRegExp)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	return files
+	for _, file := range files {
+		fmt.Printf("Config file %s found\n", file)
+		if err := cv.readCfgFile(file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getConfigFiles(tarPath, pattern string) ([]string, error) {
+	return filepath.Glob(filepath.Join(tarPath, pattern))
 }
 
-func readCfgFile(cfgFile string) {
+func (cv *Converter) readCfgFile(cfgFile string) error {
 	file, err := os.Open(cfgFile)
 	if err != nil {
-		log.Println(err)
-		return
+		return err
 	}
 	defer file.Close()
 
@@ -78,17 +105,15 @@ func readCfgFile(cfgFile string) {
 		if strings.TrimSpace(scanner.Text()) == "" {
 			continue
 		}
-		if err := readCfgLine(scanner.Text()); err != nil {
-			log.Panicln(err)
+		if err := cv.readCfgLine(scanner.Text()); err != nil {
+			return fmt.Errorf("%s: %v", cfgFile, err)
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Println(err)
-	}
+	return scanner.Err()
 }
 
-func readCfgLine(cfgLine string) error {
+func (cv *Converter) readCfgLine(cfgLine string) error {
 	parts := strings.Fields(cfgLine)
 	if len(parts) != 2 {
 		return fmt.Errorf("%v is illegel in config", cfgLine)
@@ -96,20 +121,28 @@ func readCfgLine(cfgLine string) error {
 
 	filename := parts[1]
 
-	if _, ok := sheetFileMap[filename]; !ok {
-		sheetFileMap[filename] = make([]string, 0)
+	// enum sheets are loaded separately, each of them declares an enum
+	if isEnumSheet(parts[0]) {
+		for _, sheet := range strings.Split(parts[0], ",") {
+			cv.enumFileMap[filename] = append(cv.enumFileMap[filename], sheet)
+		}
+		return nil
+	}
+
+	if _, ok := cv.sheetFileMap[filename]; !ok {
+		cv.sheetFileMap[filename] = make([]string, 0)
 	}
 
 	// check if duplicate sheet name exists
 	sheets := strings.Split(parts[0], ",")
 	for _, sheet := range sheets {
-		if _, ok := sheetNames[sheet]; ok {
+		if _, ok := cv.sheetNames[sheet]; ok {
 			// return fmt.Errorf("%s name duplicates\n", sheet)
 			fmt.Printf("Duplicate sheet name %s found\n", sheet) // Enable duplicate sheet names
 			continue
 		}
 
-		sheetNames[sheet] = struct{}{}
+		cv.sheetNames[sheet] = struct{}{}
 	}
 
 	// check multiple sheet names are the same
@@ -122,57 +155,94 @@ func readCfgLine(cfgLine string) error {
 				continue
 			}
 		}
-		sheetFileMap[filename] = append(sheetFileMap[filename], sheetName)
+		cv.sheetFileMap[filename] = append(cv.sheetFileMap[filename], sheetName)
 	} else {
-		sheetFileMap[filename] = append(sheetFileMap[filename], parts[0])
+		cv.sheetFileMap[filename] = append(cv.sheetFileMap[filename], parts[0])
 	}
 
 	return nil
 }
 
-func (c *Config) LoadConfig() {
-	/*	_, filename, _, ok := runtime.Caller(0)
-		if !ok {
-			panic("No caller information!")
+// ReplaceRelPaths convert relative paths to absolute paths, json ext is ".json" if not set
+func (c *Config) ReplaceRelPaths() error {
+	for _, path := range []*string{&c.XlsxPath, &c.ProtoOutPath, &c.DataOutPath, &c.CacheFile} {
+		absPath, err := filepath.Abs(*path)
+		if err != nil {
+			return err
 		}
-
-		cfgFile := filepath.Join(path.Dir(filename), "../conf/config.toml")*/
-	cfgFile := "./conf/config.toml"
-	if _, err := os.Stat(cfgFile); os.IsNotExist(err) {
-		panic("Config file does not exits!")
+		*path = absPath
 	}
 
-	ccfg := new(CConfig)
-	if _, err := toml.DecodeFile(cfgFile, ccfg); err != nil {
-		panic(err)
+	if c.LockFile == "" {
+		c.LockFile = filepath.Join(filepath.Dir(c.CacheFile), DefaultLockFile)
 	}
+	absPath, err := filepath.Abs(c.LockFile)
+	if err != nil {
+		return err
+	}
+	c.LockFile = absPath
 
-	*c = ccfg.Config
-
-	c.CheckDirs()
-}
-
-func (c *Config) ReplaceRelPaths() {
-	replaceRelPath := func(path *string) {
+	for _, path := range []*string{&c.JSONOutPath, &c.GoOutPath, &c.CSharpOutPath, &c.LuaOutPath} {
+		if *path == "" {
+			continue
+		}
 		absPath, err := filepath.Abs(*path)
 		if err != nil {
-			panic(err)
+			return err
 		}
 		*path = absPath
 	}
-	replaceRelPath(&c.XlsxPath)
-	replaceRelPath(&c.ProtoOutPath)
-	replaceRelPath(&c.DataOutPath)
-	replaceRelPath(&c.CacheFile)
+
+	if c.JSONOutPath != "" {
+		switch c.JSONOutExt {
+		case "":
+			c.JSONOutExt = JSONExt
+		case JSONExt, JSONLinesExt:
+		default:
+			return fmt.Errorf("json_ext %s is invalid, should be %s or %s", c.JSONOutExt, JSONExt, JSONLinesExt)
+		}
+	}
+
+	return nil
 }
 
-func (c *Config) CheckDirs() {
-	dirs := []string{cfg.ChangeOutputPath, cfg.DataOutPath, cfg.ProtoOutPath}
+// CheckDirs create output dirs if not exist
+func (c *Config) CheckDirs() error {
+	dirs := []string{c.ChangeOutputPath, c.DataOutPath, c.ProtoOutPath}
+	if c.JSONOutPath != "" {
+		dirs = append(dirs, c.JSONOutPath)
+	}
+	if c.GoOutPath != "" {
+		dirs = append(dirs, c.GoOutPath)
+	}
+	if c.CSharpOutPath != "" {
+		dirs = append(dirs, c.CSharpOutPath)
+	}
+	if c.LuaOutPath != "" {
+		dirs = append(dirs, c.LuaOutPath)
+	}
+	for _, target := range c.Targets {
+		dirs = append(dirs, filepath.Join(c.DataOutPath, target), filepath.Join(c.ProtoOutPath, target))
+		if c.JSONOutPath != "" {
+			dirs = append(dirs, filepath.Join(c.JSONOutPath, target))
+		}
+		if c.GoOutPath != "" {
+			dirs = append(dirs, filepath.Join(c.GoOutPath, target))
+		}
+		if c.CSharpOutPath != "" {
+			dirs = append(dirs, filepath.Join(c.CSharpOutPath, target))
+		}
+		if c.LuaOutPath != "" {
+			dirs = append(dirs, filepath.Join(c.LuaOutPath, target))
+		}
+	}
 	for _, dir := range dirs {
 		if err := checkOrCreateDir(dir); err != nil {
-			log.Fatal(err)
+			return err
 		}
 	}
+
+	return nil
 }
 
 func checkOrCreateDir(dir string) error {
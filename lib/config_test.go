@@ -1,14 +1,86 @@
 package lib
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
+func newTestConfig() *Config {
+	return &Config{
+		ConfigRegExp: "xlsx*.config",
+		XlsxPath:     "../test/",
+		PackageName:  "ProtobufGen",
+		ProtoOutPath: "../test/",
+		ProtoOutExt:  ".proto",
+		DataOutPath:  "../test/",
+		DataOutExt:   ".data",
+		CacheFile:    "../cache/sheetcachetest.json",
+	}
+}
+
+// newTempConfig read xlsx from test dir, and output all files to temp dirs
+func newTempConfig(t *testing.T) *Config {
+	cfg := newTestConfig()
+	cfg.ProtoOutPath = t.TempDir()
+	cfg.DataOutPath = t.TempDir()
+	cfg.CacheFile = filepath.Join(t.TempDir(), "cache.json")
+	cfg.LockFile = filepath.Join(t.TempDir(), DefaultLockFile)
+	cfg.ChangeOutputPath = t.TempDir()
+	cfg.ChangeLog = filepath.Join(cfg.ChangeOutputPath, "changelog.json")
+	for _, dir := range []string{"proto", "data"} {
+		if err := os.Mkdir(filepath.Join(cfg.ChangeOutputPath, dir), 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return cfg
+}
+
+func TestLoadConfig(t *testing.T) {
+	c, err := LoadConfig("../conf/config.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, "xlsx*.config", c.ConfigRegExp)
+	assert.Equal(t, "ProtobufGen", c.PackageName)
+
+	_, err = LoadConfig("../conf/notexist.toml")
+	assert.Error(t, err)
+}
+
+func TestReplaceRelPaths(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.JSONOutPath = "../test/json"
+	assert.NoError(t, cfg.ReplaceRelPaths())
+	assert.True(t, filepath.IsAbs(cfg.JSONOutPath))
+	assert.Equal(t, JSONExt, cfg.JSONOutExt)
+
+	cfg.JSONOutExt = ".txt"
+	assert.Error(t, cfg.ReplaceRelPaths())
+
+	// ext is not needed without json output
+	cfg = newTestConfig()
+	assert.NoError(t, cfg.ReplaceRelPaths())
+	assert.Equal(t, "", cfg.JSONOutExt)
+}
+
+func TestLoadSheetConfigs(t *testing.T) {
+	cv := newConverter(newTestConfig())
+	cv.cfg.ConfigRegExp = "xlsx_sample.config"
+
+	assert.NoError(t, cv.LoadSheetConfigs())
+	assert.Equal(t, 1, len(cv.sheetFileMap))
+	assert.Equal(t, 3, len(cv.sheetFileMap["Sample.xlsx"]))
+
+	// converters do not share sheets
+	assert.Equal(t, 0, len(newConverter(newTestConfig()).sheetNames))
+}
+
 func TestGetConfigFiles(t *testing.T) {
 	path := "../test"
-	files := getConfigFiles(path)
+	files, err := getConfigFiles(path, "xlsx*.config")
+	assert.NoError(t, err)
 
 	assert.Equal(t, 3, len(files))
 	assert.Contains(t, files[0], "xlsx_sample.config")
@@ -17,20 +89,20 @@ func TestGetConfigFiles(t *testing.T) {
 }
 
 func TestReadCfgFile(t *testing.T) {
-	ResetConfigCache()
+	cv := newConverter(newTestConfig())
 
 	file1 := "../test/xlsx_sample.config"
 
-	readCfgFile(file1)
-	assert.Equal(t, 4, len(sheetNames))
+	assert.NoError(t, cv.readCfgFile(file1))
+	assert.Equal(t, 4, len(cv.sheetNames))
 
-	// panic if config files contains incorrect lines
+	// error if config files contains incorrect lines
 	file2 := "../test/xlsx_sample_wrong.config"
-	assert.NotPanics(t, func() { readCfgFile(file2) })
+	assert.NoError(t, cv.readCfgFile(file2))
 }
 
 func TestReadCfgLine(t *testing.T) {
-	ResetConfigCache()
+	cv := newConverter(newTestConfig())
 
 	tests := []struct {
 		in      string
@@ -45,6 +117,6 @@ func TestReadCfgLine(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		assert.Equal(t, test.isError, readCfgLine(test.in) != nil, "test: %v", test)
+		assert.Equal(t, test.isError, cv.readCfgLine(test.in) != nil, "test: %v", test)
 	}
 }
@@ -0,0 +1,211 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Constraints follow the type in type cell, e.g. "int32[0..100]", "float[>0]" or "string~^[A-Z_]+$"
+// a range is one of "[min..max]", "[min..]", "[..max]", "[>x]", "[>=x]", "[<x]" and "[<=x]"
+const (
+	RangePrefix   = "["
+	RangeSuffix   = "]"
+	RangeSep      = ".."
+	PatternPrefix = "~"
+)
+
+var (
+	ErrConstraintInvalid  = errors.New("invalid constraint")
+	ErrConstraintViolated = errors.New("constraint violated")
+)
+
+// kinds of range types, bounds are parsed and compared in the same kind as values, so 64 bit integers are exact
+const (
+	rangeInt = iota
+	rangeUint
+	rangeFloat
+)
+
+var rangeTypes = map[string]int{
+	"int32": rangeInt, "int64": rangeInt, "sint32": rangeInt, "sint64": rangeInt,
+	"uint32": rangeUint, "uint64": rangeUint,
+	"float": rangeFloat, "float32": rangeFloat, "float64": rangeFloat, "double": rangeFloat,
+}
+
+// Constraint limits values of a column, checked when cells are read
+type Constraint struct {
+	text    string // as declared in type cell
+	min     *bound
+	max     *bound
+	pattern *regexp.Regexp
+}
+
+type bound struct {
+	kind      int // rangeInt, rangeUint or rangeFloat
+	intVal    int64
+	uintVal   uint64
+	value     float64
+	exclusive bool
+}
+
+// parseConstraint split constraint from type of val, inline types keep constraints in their element types
+func (val *Val) parseConstraint() error {
+	if isInlineType(val.typ) {
+		return nil
+	}
+
+	idx := strings.IndexAny(val.typ, RangePrefix+PatternPrefix)
+	if idx < 0 {
+		return nil
+	}
+
+	typ, text := val.typ[:idx], val.typ[idx:]
+	c, err := newConstraint(typ, text)
+	if err != nil {
+		return &SheetError{Col: val.colIdx + 1, Field: val.name, Err: err}
+	}
+	val.typ, val.constraint = typ, c
+
+	return nil
+}
+
+func newConstraint(typ, text string) (*Constraint, error) {
+	c := &Constraint{text: text}
+
+	if strings.HasPrefix(text, PatternPrefix) {
+		if typ != "string" {
+			return nil, fmt.Errorf("%w %s, pattern only works with string", ErrConstraintInvalid, text)
+		}
+		pattern, err := regexp.Compile(strings.TrimPrefix(text, PatternPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("%w %s, %v", ErrConstraintInvalid, text, err)
+		}
+		c.pattern = pattern
+		return c, nil
+	}
+
+	kind, ok := rangeTypes[typ]
+	if !ok {
+		return nil, fmt.Errorf("%w %s, range only works with numbers", ErrConstraintInvalid, text)
+	}
+	if !strings.HasSuffix(text, RangeSuffix) {
+		return nil, fmt.Errorf("%w %s, range should end with %s", ErrConstraintInvalid, text, RangeSuffix)
+	}
+
+	var err error
+	expr := strings.TrimSuffix(strings.TrimPrefix(text, RangePrefix), RangeSuffix)
+	switch {
+	case strings.Contains(expr, RangeSep):
+		parts := strings.SplitN(expr, RangeSep, 2)
+		if parts[0] != "" {
+			if c.min, err = parseBound(parts[0], kind, false); err != nil {
+				break
+			}
+		}
+		if parts[1] != "" {
+			c.max, err = parseBound(parts[1], kind, false)
+		}
+	case strings.HasPrefix(expr, ">="):
+		c.min, err = parseBound(expr[2:], kind, false)
+	case strings.HasPrefix(expr, ">"):
+		c.min, err = parseBound(expr[1:], kind, true)
+	case strings.HasPrefix(expr, "<="):
+		c.max, err = parseBound(expr[2:], kind, false)
+	case strings.HasPrefix(expr, "<"):
+		c.max, err = parseBound(expr[1:], kind, true)
+	}
+	if err != nil || (c.min == nil && c.max == nil) {
+		return nil, fmt.Errorf("%w %s", ErrConstraintInvalid, text)
+	}
+	if c.min != nil && c.max != nil && c.min.compare(c.max) > 0 {
+		return nil, fmt.Errorf("%w %s, min is greater than max", ErrConstraintInvalid, text)
+	}
+
+	return c, nil
+}
+
+// parseBound parse a bound in the kind of column type, bounds of integer columns should be integers
+func parseBound(s string, kind int, exclusive bool) (*bound, error) {
+	b := &bound{kind: kind, exclusive: exclusive}
+	var err error
+	switch s = strings.TrimSpace(s); kind {
+	case rangeInt:
+		b.intVal, err = strconv.ParseInt(s, 10, 64)
+	case rangeUint:
+		b.uintVal, err = strconv.ParseUint(s, 10, 64)
+	default:
+		b.value, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// compare returns -1, 0 or 1 if b is less than, equal to or greater than other bound of the same kind
+func (b *bound) compare(other *bound) int {
+	switch b.kind {
+	case rangeInt:
+		return compareValue(b.intVal < other.intVal, b.intVal > other.intVal)
+	case rangeUint:
+		return compareValue(b.uintVal < other.uintVal, b.uintVal > other.uintVal)
+	}
+
+	return compareValue(b.value < other.value, b.value > other.value)
+}
+
+// compareValue returns -1, 0 or 1 if a value is less than, equal to or greater than a bound
+func (b *bound) compareValue(v protoreflect.Value) int {
+	switch n := v.Interface().(type) {
+	case int32, int64:
+		return compareValue(v.Int() < b.intVal, v.Int() > b.intVal)
+	case uint32, uint64:
+		return compareValue(v.Uint() < b.uintVal, v.Uint() > b.uintVal)
+	case float32:
+		// bounds of float columns are compared in float32 too, so "0.1" is in "[..0.1]"
+		limit := float32(b.value)
+		return compareValue(n < limit, n > limit)
+	}
+
+	return compareValue(v.Float() < b.value, v.Float() > b.value)
+}
+
+func compareValue(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+
+	return 0
+}
+
+// check returns ErrConstraintViolated if value read from cell is out of range or does not match pattern
+func (c *Constraint) check(v protoreflect.Value) error {
+	if c.pattern != nil {
+		if !c.pattern.MatchString(v.String()) {
+			return fmt.Errorf("%w, %q does not match %s", ErrConstraintViolated, v.String(), c.text)
+		}
+		return nil
+	}
+
+	switch v.Interface().(type) {
+	case int32, int64, uint32, uint64, float32, float64:
+	default:
+		return nil
+	}
+
+	if c.min != nil && (c.min.compareValue(v) < 0 || c.min.exclusive && c.min.compareValue(v) == 0) ||
+		c.max != nil && (c.max.compareValue(v) > 0 || c.max.exclusive && c.max.compareValue(v) == 0) {
+		return fmt.Errorf("%w, %v is out of %s", ErrConstraintViolated, v.Interface(), c.text)
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		typ   string
+		base  string
+		valid []string
+		wrong []string
+	}{
+		{"int32[0..100]", "int32", []string{"0", "100", "50"}, []string{"-1", "101"}},
+		{"uint32[10..]", "uint32", []string{"10", "99999"}, []string{"9"}},
+		{"sint64[..-1]", "sint64", []string{"-1", "-500"}, []string{"0"}},
+		{"float[>0]", "float", []string{"0.001", "3"}, []string{"0", "-0.5"}},
+		{"float[<=0.1]", "float", []string{"0.1", "-2"}, []string{"0.11"}},
+		{"double[<1]", "double", []string{"0.999"}, []string{"1", "1.5"}},
+		{"int64[>=5]", "int64", []string{"5"}, []string{"4"}},
+		{"int64[>-9007199254740993]", "int64", []string{"-9007199254740992"}, []string{"-9007199254740993"}},
+		{"uint64[..9007199254740992]", "uint64", []string{"9007199254740992", "3.0"}, []string{"9007199254740993"}},
+		{"string~^[A-Z_]+$", "string", []string{"SWORD", "FIRE_BALL", " ICE "}, []string{"sword", "A-B"}},
+	}
+
+	for _, test := range tests {
+		val := &Val{proto2Type: Opt, typ: test.typ}
+		assert.NoError(t, val.parseConstraint(), test.typ)
+		assert.Equal(t, test.base, val.typ)
+		for _, cell := range test.valid {
+			_, ok, err := readCell(val, cell)
+			assert.NoError(t, err, test.typ+" "+cell)
+			assert.True(t, ok)
+		}
+		for _, cell := range test.wrong {
+			_, _, err := readCell(val, cell)
+			assert.True(t, errors.Is(err, ErrConstraintViolated), test.typ+" "+cell)
+		}
+
+		// empty cells use default values
+		_, ok, err := readCell(val, "")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	}
+
+	for _, typ := range []string{"int32[]", "int32[a..b]", "int32[5..1]", "int32[0.5..1]", "uint32[-1..]", "int32[0..1", "bool[0..1]", "int32~^1$", "string~[", "enum:ItemQuality[1..2]"} {
+		val := &Val{proto2Type: Opt, typ: typ}
+		assert.True(t, errors.Is(val.parseConstraint(), ErrConstraintInvalid), typ)
+	}
+
+	val := &Val{proto2Type: Opt, typ: "struct{id:uint32[1..],count:uint32}[]"}
+	assert.NoError(t, val.parseConstraint())
+	assert.Nil(t, val.constraint)
+}
+
+func TestConstraintViolated(t *testing.T) {
+	tests := []struct {
+		rows  [][]string
+		col   int
+		field string
+	}{
+		{
+			[][]string{
+				{"unique", "optional"},
+				{"uint32", "float[0..1] "},
+				{"ID", "Rate"},
+				{"", ""},
+				{"1", "0.5"},
+				{"2", "1.5"},
+			},
+			2, "Rate",
+		},
+		{
+			[][]string{
+				{"unique", "optional"},
+				{"uint32", "list<int32[>0]>"},
+				{"ID", "Prices"},
+				{"", ""},
+				{"1", "5;-3"},
+			},
+			2, "Prices",
+		},
+		{
+			[][]string{
+				{"unique", "repeated", "optional", "optional"},
+				{"uint32", "2", "uint32[1..9]", "uint32[1..9]"},
+				{"ID", "", "Levels", "Levels"},
+				{"", "", "", ""},
+				{"1", "2", "3", "10"},
+			},
+			4, "Levels",
+		},
+		{
+			[][]string{
+				{"unique", "optional"},
+				{"uint32", "map<uint32,int32[0..100]>"},
+				{"ID", "Weights"},
+				{"", ""},
+				{"1", "1001:150"},
+			},
+			2, "Weights",
+		},
+	}
+
+	for _, test := range tests {
+		sheet := newTestSheet("CONSTRAINTTEST", test.rows)
+		pr := newProtoRow(newTestConfig())
+		assert.NoError(t, pr.updateHeads(sheet))
+		err := pr.readData(sheet)
+		assert.True(t, errors.Is(err, ErrConstraintViolated), test.field)
+
+		var se *SheetError
+		if assert.True(t, errors.As(err, &se)) {
+			assert.Equal(t, len(test.rows), se.Row, test.field)
+			assert.Equal(t, test.col, se.Col, test.field)
+			assert.Equal(t, test.field, se.Field)
+		}
+	}
+}
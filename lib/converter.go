@@ -0,0 +1,59 @@
+package lib
+
+import "sync"
+
+// Converter converts xlsx files listed in xlsx*.config to proto and binary data files
+// Each converter holds its own config, sheet list and cache, so several converters can live in one process
+type Converter struct {
+	cfg          *Config
+	sheetNames   map[string]struct{} // check if duplicate sheet name exists
+	sheetFileMap map[string][]string // map[filename][]sheetnames, e.g. ["Sheet1", "Sheet2, Sheet3"]
+	enumFileMap  map[string][]string // map[filename][]enum sheetnames, e.g. ["ENUM_ItemQuality"]
+	enums        map[string]*Enum    // map[enumName]enum, loaded before other sheets
+	enumsChanged bool                // sheets should be converted again if enums changed
+	refInfos     map[string]*RefInfo // map[sheetName]ref info, used to validate references across sheets
+	fieldLock    *FieldLock          // field numbers of all sheets, loaded from lock file in Run
+	cacher       *Cacher             // nil if cache is off
+	strictCompat bool                // incompatible proto changes fail the sheet instead of warning
+	dryRun       bool                // no file is written
+	changes      *Cacher
+	sources      *sourceCache   // files shared by sheets in a run, nil if not running
+	rowChanges   []*SheetChange // rows changed since last run, only collected if cache is on
+
+	mutex sync.Mutex
+}
+
+// NewConverter create a converter, output dirs are created and xlsx*.config files are loaded
+func NewConverter(config Config) (*Converter, error) {
+	cv := newConverter(&config)
+
+	if err := cv.cfg.ReplaceRelPaths(); err != nil {
+		return nil, err
+	}
+	if err := cv.cfg.checkStream(); err != nil {
+		return nil, err
+	}
+	if err := cv.cfg.CheckDirs(); err != nil {
+		return nil, err
+	}
+	if err := cv.LoadSheetConfigs(); err != nil {
+		return nil, err
+	}
+
+	return cv, nil
+}
+
+func newConverter(cfg *Config) *Converter {
+	cv := new(Converter)
+	cv.cfg = cfg
+	cv.refInfos = make(map[string]*RefInfo)
+	cv.fieldLock = newFieldLock()
+	cv.ResetConfigCache()
+
+	return cv
+}
+
+// Config returns the config used by converter
+func (cv *Converter) Config() Config {
+	return *cv.cfg
+}
@@ -0,0 +1,707 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// C# loaders are self contained, data is parsed by the shared ProtoReader without reflection
+const (
+	CSharpExt        = ".loader.cs"
+	CSharpReaderFile = "ProtoReader.cs"
+)
+
+// csharpReader decodes protobuf wire format, written once in each C# output dir
+const csharpReader = `// ProtoReader decodes protobuf wire format for generated loaders, numbers are read in little endian
+public sealed class ProtoReader
+{
+    private readonly byte[] buf;
+
+    public int Position;
+
+    public ProtoReader(byte[] buf)
+    {
+        this.buf = buf;
+    }
+
+    public ulong ReadVarint()
+    {
+        ulong result = 0;
+        for (int shift = 0; shift < 64; shift += 7)
+        {
+            byte b = buf[Position++];
+            result |= (ulong)(b & 0x7F) << shift;
+            if (b < 0x80)
+            {
+                return result;
+            }
+        }
+        throw new System.FormatException("malformed varint");
+    }
+
+    public uint ReadTag()
+    {
+        return (uint)ReadVarint();
+    }
+
+    // ReadLimit read length of a length delimited field, returns position of its end
+    public int ReadLimit()
+    {
+        ulong length = ReadVarint();
+        if (length > (ulong)(buf.Length - Position))
+        {
+            throw new System.FormatException("truncated field");
+        }
+        return Position + (int)length;
+    }
+
+    public int ReadInt32() { return (int)ReadVarint(); }
+    public long ReadInt64() { return (long)ReadVarint(); }
+    public uint ReadUInt32() { return (uint)ReadVarint(); }
+    public ulong ReadUInt64() { return ReadVarint(); }
+    public bool ReadBool() { return ReadVarint() != 0; }
+
+    public int ReadSInt32()
+    {
+        uint n = (uint)ReadVarint();
+        return (int)(n >> 1) ^ -(int)(n & 1);
+    }
+
+    public long ReadSInt64()
+    {
+        ulong n = ReadVarint();
+        return (long)(n >> 1) ^ -(long)(n & 1);
+    }
+
+    public uint ReadFixed32()
+    {
+        uint v = System.BitConverter.ToUInt32(buf, Position);
+        Position += 4;
+        return v;
+    }
+
+    public ulong ReadFixed64()
+    {
+        ulong v = System.BitConverter.ToUInt64(buf, Position);
+        Position += 8;
+        return v;
+    }
+
+    public int ReadSFixed32() { return (int)ReadFixed32(); }
+    public long ReadSFixed64() { return (long)ReadFixed64(); }
+
+    public float ReadFloat()
+    {
+        float v = System.BitConverter.ToSingle(buf, Position);
+        Position += 4;
+        return v;
+    }
+
+    public double ReadDouble()
+    {
+        double v = System.BitConverter.ToDouble(buf, Position);
+        Position += 8;
+        return v;
+    }
+
+    public string ReadString()
+    {
+        int end = ReadLimit();
+        string s = System.Text.Encoding.UTF8.GetString(buf, Position, end - Position);
+        Position = end;
+        return s;
+    }
+
+    public byte[] ReadBytes()
+    {
+        int end = ReadLimit();
+        byte[] b = new byte[end - Position];
+        System.Array.Copy(buf, Position, b, 0, b.Length);
+        Position = end;
+        return b;
+    }
+
+    public void SkipField(uint tag)
+    {
+        switch (tag & 7)
+        {
+            case 0:
+                ReadVarint();
+                break;
+            case 1:
+                Position += 8;
+                break;
+            case 2:
+                Position = ReadLimit();
+                break;
+            case 5:
+                Position += 4;
+                break;
+            default:
+                throw new System.FormatException("unsupported wire type " + (tag & 7));
+        }
+    }
+}
+`
+
+// csharpKeywords can not be used as parameter names
+var csharpKeywords = map[string]struct{}{
+	"abstract": {}, "as": {}, "base": {}, "bool": {}, "break": {}, "byte": {}, "case": {}, "catch": {}, "char": {},
+	"checked": {}, "class": {}, "const": {}, "continue": {}, "decimal": {}, "default": {}, "delegate": {}, "do": {},
+	"double": {}, "else": {}, "enum": {}, "event": {}, "explicit": {}, "extern": {}, "false": {}, "finally": {},
+	"fixed": {}, "float": {}, "for": {}, "foreach": {}, "goto": {}, "if": {}, "implicit": {}, "in": {}, "int": {},
+	"interface": {}, "internal": {}, "is": {}, "lock": {}, "long": {}, "namespace": {}, "new": {}, "null": {},
+	"object": {}, "operator": {}, "out": {}, "override": {}, "params": {}, "private": {}, "protected": {},
+	"public": {}, "readonly": {}, "ref": {}, "return": {}, "sbyte": {}, "sealed": {}, "short": {}, "sizeof": {},
+	"stackalloc": {}, "static": {}, "string": {}, "struct": {}, "switch": {}, "this": {}, "throw": {}, "true": {},
+	"try": {}, "typeof": {}, "uint": {}, "ulong": {}, "unchecked": {}, "unsafe": {}, "ushort": {}, "using": {},
+	"virtual": {}, "void": {}, "volatile": {}, "while": {},
+}
+
+// codeWriter indents lines by braces, 4 spaces for each level
+type codeWriter struct {
+	buf    bytes.Buffer
+	indent int
+}
+
+func (w *codeWriter) line(format string, args ...interface{}) {
+	text := fmt.Sprintf(format, args...)
+	if strings.HasPrefix(text, "}") {
+		w.indent--
+	}
+	if text != "" {
+		w.buf.WriteString(strings.Repeat("    ", w.indent))
+	}
+	w.buf.WriteString(text + "\n")
+	if strings.HasSuffix(text, "{") {
+		w.indent++
+	}
+}
+
+// csharpFile add header and namespace to code
+func csharpFile(source, namespace, code string) []byte {
+	var w codeWriter
+	w.line("// Code generated by xlsx2pb. DO NOT EDIT.")
+	if source != "" {
+		w.line("// source: %s", source)
+	}
+	w.line("")
+	w.line("using System.Collections.Generic;")
+	w.line("")
+	if namespace == "" {
+		w.buf.WriteString(code + "\n")
+		return w.buf.Bytes()
+	}
+
+	w.line("namespace %s", namespace)
+	w.line("{")
+	for _, text := range strings.Split(strings.TrimSuffix(code, "\n"), "\n") {
+		if text != "" {
+			w.buf.WriteString("    ")
+		}
+		w.buf.WriteString(text + "\n")
+	}
+	w.line("}")
+
+	return w.buf.Bytes()
+}
+
+// GenCSharp generate C# classes of current sheet, XXX_ARRAY has a static Load to parse data file and build dictionaries of unique columns
+func (pr *ProtoSheet) GenCSharp() ([]byte, error) {
+	var w codeWriter
+
+	file := pr.msgDesc.ParentFile()
+	for i := 0; i < file.Messages().Len(); i++ {
+		if err := pr.addCSharpMessage(&w, file.Messages().Get(i)); err != nil {
+			return nil, err
+		}
+	}
+
+	return csharpFile(pr.fileDesc.GetName(), pr.fileDesc.GetPackage(), strings.TrimSuffix(w.buf.String(), "\n")), nil
+}
+
+// addCSharpMessage add enums, nested types and class of a message, nested types are named as "SHEET_Struct"
+func (pr *ProtoSheet) addCSharpMessage(w *codeWriter, msg protoreflect.MessageDescriptor) error {
+	for i := 0; i < msg.Enums().Len(); i++ {
+		e := msg.Enums().Get(i)
+		w.line("public enum %s", pr.goName(e.FullName()))
+		w.line("{")
+		for j := 0; j < e.Values().Len(); j++ {
+			v := e.Values().Get(j)
+			w.line("%s = %d,", v.Name(), v.Number())
+		}
+		w.line("}")
+		w.line("")
+	}
+	for i := 0; i < msg.Messages().Len(); i++ {
+		if nested := msg.Messages().Get(i); !nested.IsMapEntry() {
+			if err := pr.addCSharpMessage(w, nested); err != nil {
+				return err
+			}
+		}
+	}
+
+	className := pr.goName(msg.FullName())
+	fields := msg.Fields()
+	w.line("public sealed class %s", className)
+	w.line("{")
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		typ, err := pr.csharpFieldType(field)
+		if err != nil {
+			return err
+		}
+		switch {
+		case field.IsList() || field.IsMap():
+			w.line("public %s %s = new %s();", typ, csharpMember(field, className), typ)
+		case field.Kind() == protoreflect.EnumKind || field.Kind() == protoreflect.StringKind || !isZeroLiteral(pr.csharpValue(field, field.Default())):
+			w.line("public %s %s = %s;", typ, csharpMember(field, className), pr.csharpValue(field, field.Default()))
+		default:
+			w.line("public %s %s;", typ, csharpMember(field, className))
+		}
+	}
+
+	if msg.FullName() == pr.arrayDesc.FullName() {
+		pr.addCSharpLoad(w, className, pr.loaderIndexes())
+	}
+
+	w.line("")
+	w.line("internal void Read(ProtoReader r, int end)")
+	w.line("{")
+	w.line("while (r.Position < end)")
+	w.line("{")
+	w.line("uint tag = r.ReadTag();")
+	w.line("switch (tag)")
+	w.line("{")
+	for i := 0; i < fields.Len(); i++ {
+		if err := pr.addCSharpRead(w, fields.Get(i), className); err != nil {
+			return err
+		}
+	}
+	w.line("default:")
+	w.indent++
+	w.line("r.SkipField(tag);")
+	w.line("break;")
+	w.indent--
+	w.line("}")
+	w.line("}")
+	w.line("}")
+
+	w.line("}")
+	w.line("")
+
+	return nil
+}
+
+// addCSharpLoad add dictionaries of unique columns, static Load and GetBy methods to XXX_ARRAY class
+func (pr *ProtoSheet) addCSharpLoad(w *codeWriter, className string, indexes []*loaderIndex) {
+	itemType := pr.goName(pr.msgDesc.FullName())
+	items := goCamelCase(string(pr.arrayDesc.Fields().ByNumber(1).Name()))
+
+	keyTypes := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		var types []string
+		for _, field := range index.fields {
+			typ, _ := pr.csharpFieldType(field)
+			types = append(types, typ)
+		}
+		keyTypes = append(keyTypes, csharpTuple(types))
+	}
+
+	if len(indexes) > 0 {
+		w.line("")
+	}
+	for i, index := range indexes {
+		typ := fmt.Sprintf("Dictionary<%s, %s>", keyTypes[i], itemType)
+		w.line("public readonly %s By%s = new %s();", typ, goCamelCase(index.name), typ)
+	}
+
+	w.line("")
+	w.line("// Load parses data file of %s, and builds dictionaries of unique columns", pr.Name)
+	w.line("public static %s Load(byte[] data)", className)
+	w.line("{")
+	w.line("var array = new %s();", className)
+	w.line("array.Read(new ProtoReader(data), data.Length);")
+	if len(indexes) > 0 {
+		w.line("foreach (var item in array.%s)", items)
+		w.line("{")
+		for _, index := range indexes {
+			var values []string
+			for _, field := range index.fields {
+				values = append(values, "item."+csharpMember(field, itemType))
+			}
+			w.line("array.By%s[%s] = item;", goCamelCase(index.name), csharpTuple(values))
+		}
+		w.line("}")
+	}
+	w.line("return array;")
+	w.line("}")
+
+	for _, index := range indexes {
+		var names, params, args []string
+		for _, field := range index.fields {
+			names = append(names, string(field.Name()))
+			typ, _ := pr.csharpFieldType(field)
+			params = append(params, typ+" "+csharpParamName(string(field.Name())))
+			args = append(args, csharpParamName(string(field.Name())))
+		}
+		w.line("")
+		w.line("// GetBy%s returns the item with unique %s, or null if not found", goCamelCase(index.name), strings.Join(names, " and "))
+		w.line("public %s GetBy%s(%s)", itemType, goCamelCase(index.name), strings.Join(params, ", "))
+		w.line("{")
+		w.line("%s found;", itemType)
+		w.line("return By%s.TryGetValue(%s, out found) ? found : null;", goCamelCase(index.name), csharpTuple(args))
+		w.line("}")
+	}
+}
+
+// addCSharpRead add cases to read a field, repeated numbers can be packed or not
+func (pr *ProtoSheet) addCSharpRead(w *codeWriter, field protoreflect.FieldDescriptor, className string) error {
+	member := csharpMember(field, className)
+	num := uint32(field.Number())
+
+	switch {
+	case field.IsMap():
+		keyType, err := pr.csharpFieldType(field.MapKey())
+		if err != nil {
+			return err
+		}
+		valueType, err := pr.csharpFieldType(field.MapValue())
+		if err != nil {
+			return err
+		}
+		w.line("case %d:", num<<3|2)
+		w.indent++
+		w.line("{")
+		w.line("int limit = r.ReadLimit();")
+		w.line("%s key = %s;", keyType, pr.csharpValue(field.MapKey(), field.MapKey().Default()))
+		if field.MapValue().Kind() == protoreflect.MessageKind {
+			w.line("%s value = new %s();", valueType, valueType)
+		} else {
+			w.line("%s value = %s;", valueType, pr.csharpValue(field.MapValue(), field.MapValue().Default()))
+		}
+		w.line("while (r.Position < limit)")
+		w.line("{")
+		w.line("uint entryTag = r.ReadTag();")
+		w.line("switch (entryTag)")
+		w.line("{")
+		for _, entry := range []struct {
+			name  string
+			field protoreflect.FieldDescriptor
+		}{{"key", field.MapKey()}, {"value", field.MapValue()}} {
+			w.line("case %d:", uint32(entry.field.Number())<<3|csharpWireType(entry.field))
+			w.indent++
+			if entry.field.Kind() == protoreflect.MessageKind {
+				w.line("%s.Read(r, r.ReadLimit());", entry.name)
+			} else {
+				w.line("%s = %s;", entry.name, pr.csharpRead(entry.field))
+			}
+			w.line("break;")
+			w.indent--
+		}
+		w.line("default:")
+		w.indent++
+		w.line("r.SkipField(entryTag);")
+		w.line("break;")
+		w.indent--
+		w.line("}")
+		w.line("}")
+		w.line("%s[key] = value;", member)
+		w.line("}")
+		w.line("break;")
+		w.indent--
+
+	case field.Kind() == protoreflect.MessageKind:
+		typ := pr.goName(field.Message().FullName())
+		w.line("case %d:", num<<3|2)
+		w.indent++
+		w.line("{")
+		if field.IsList() {
+			w.line("var item = new %s();", typ)
+			w.line("item.Read(r, r.ReadLimit());")
+			w.line("%s.Add(item);", member)
+		} else {
+			w.line("if (%s == null)", member)
+			w.line("{")
+			w.line("%s = new %s();", member, typ)
+			w.line("}")
+			w.line("%s.Read(r, r.ReadLimit());", member)
+		}
+		w.line("}")
+		w.line("break;")
+		w.indent--
+
+	case field.IsList():
+		wireType := csharpWireType(field)
+		w.line("case %d:", num<<3|wireType)
+		w.indent++
+		w.line("%s.Add(%s);", member, pr.csharpRead(field))
+		w.line("break;")
+		w.indent--
+		if wireType == 2 { // strings and bytes are never packed
+			break
+		}
+		w.line("case %d:", num<<3|2)
+		w.indent++
+		w.line("{")
+		w.line("int limit = r.ReadLimit();")
+		w.line("while (r.Position < limit)")
+		w.line("{")
+		w.line("%s.Add(%s);", member, pr.csharpRead(field))
+		w.line("}")
+		w.line("}")
+		w.line("break;")
+		w.indent--
+
+	default:
+		w.line("case %d:", num<<3|csharpWireType(field))
+		w.indent++
+		w.line("%s = %s;", member, pr.csharpRead(field))
+		w.line("break;")
+		w.indent--
+	}
+
+	return nil
+}
+
+// csharpFieldType returns C# type of a field, repeated fields are lists and maps are dictionaries
+func (pr *ProtoSheet) csharpFieldType(field protoreflect.FieldDescriptor) (string, error) {
+	if field.IsMap() {
+		keyType, err := pr.csharpFieldType(field.MapKey())
+		if err != nil {
+			return "", err
+		}
+		valueType, err := pr.csharpFieldType(field.MapValue())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Dictionary<%s, %s>", keyType, valueType), nil
+	}
+
+	var typ string
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		typ = "bool"
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		typ = "int"
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		typ = "uint"
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		typ = "long"
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		typ = "ulong"
+	case protoreflect.FloatKind:
+		typ = "float"
+	case protoreflect.DoubleKind:
+		typ = "double"
+	case protoreflect.StringKind:
+		typ = "string"
+	case protoreflect.BytesKind:
+		typ = "byte[]"
+	case protoreflect.EnumKind:
+		typ = pr.goName(field.Enum().FullName())
+	case protoreflect.MessageKind:
+		typ = pr.goName(field.Message().FullName())
+	default:
+		return "", fmt.Errorf("field %s of type %v is not supported in C#", field.FullName(), field.Kind())
+	}
+
+	if field.IsList() {
+		return fmt.Sprintf("List<%s>", typ), nil
+	}
+
+	return typ, nil
+}
+
+// csharpRead returns expression to read a scalar or enum value
+func (pr *ProtoSheet) csharpRead(field protoreflect.FieldDescriptor) string {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return "r.ReadBool()"
+	case protoreflect.Int32Kind:
+		return "r.ReadInt32()"
+	case protoreflect.Sint32Kind:
+		return "r.ReadSInt32()"
+	case protoreflect.Sfixed32Kind:
+		return "r.ReadSFixed32()"
+	case protoreflect.Uint32Kind:
+		return "r.ReadUInt32()"
+	case protoreflect.Fixed32Kind:
+		return "r.ReadFixed32()"
+	case protoreflect.Int64Kind:
+		return "r.ReadInt64()"
+	case protoreflect.Sint64Kind:
+		return "r.ReadSInt64()"
+	case protoreflect.Sfixed64Kind:
+		return "r.ReadSFixed64()"
+	case protoreflect.Uint64Kind:
+		return "r.ReadUInt64()"
+	case protoreflect.Fixed64Kind:
+		return "r.ReadFixed64()"
+	case protoreflect.FloatKind:
+		return "r.ReadFloat()"
+	case protoreflect.DoubleKind:
+		return "r.ReadDouble()"
+	case protoreflect.BytesKind:
+		return "r.ReadBytes()"
+	case protoreflect.EnumKind:
+		return fmt.Sprintf("(%s)r.ReadInt32()", pr.goName(field.Enum().FullName()))
+	}
+
+	return "r.ReadString()"
+}
+
+// csharpValue returns C# literal of a scalar or enum value
+func (pr *ProtoSheet) csharpValue(field protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(v.Bool())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return strconv.FormatInt(v.Int(), 10)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(v.Int(), 10) + "L"
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return strconv.FormatUint(v.Uint(), 10) + "U"
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10) + "UL"
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		typ, suffix, bitSize := "float", "f", 32
+		if field.Kind() == protoreflect.DoubleKind {
+			typ, suffix, bitSize = "double", "d", 64
+		}
+		switch f := v.Float(); {
+		case math.IsInf(f, 1):
+			return typ + ".PositiveInfinity"
+		case math.IsInf(f, -1):
+			return typ + ".NegativeInfinity"
+		case math.IsNaN(f):
+			return typ + ".NaN"
+		default:
+			return strconv.FormatFloat(f, 'g', -1, bitSize) + suffix
+		}
+	case protoreflect.EnumKind:
+		if value := field.Enum().Values().ByNumber(v.Enum()); value != nil {
+			return fmt.Sprintf("%s.%s", pr.goName(field.Enum().FullName()), value.Name())
+		}
+		return fmt.Sprintf("(%s)%d", pr.goName(field.Enum().FullName()), v.Enum())
+	case protoreflect.StringKind:
+		return csharpQuote(v.String())
+	}
+
+	return "null"
+}
+
+// isZeroLiteral check if a literal is the default value of C# numbers and bool, fields with it need no initializer
+func isZeroLiteral(value string) bool {
+	switch value {
+	case "0", "0L", "0U", "0UL", "0f", "0d", "false", "null":
+		return true
+	}
+
+	return false
+}
+
+func csharpWireType(field protoreflect.FieldDescriptor) uint32 {
+	switch field.Kind() {
+	case protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind, protoreflect.FloatKind:
+		return 5
+	case protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind, protoreflect.DoubleKind:
+		return 1
+	case protoreflect.StringKind, protoreflect.BytesKind, protoreflect.MessageKind:
+		return 2
+	}
+
+	return 0
+}
+
+// csharpMember returns name of the member for a field, which can not be the same as its class
+func csharpMember(field protoreflect.FieldDescriptor, className string) string {
+	name := goCamelCase(string(field.Name()))
+	if name == className {
+		name += "_"
+	}
+
+	return name
+}
+
+// csharpParamName returns name of parameter for a field, e.g. "itemID" for "ItemID"
+func csharpParamName(name string) string {
+	name = lowerCamelCase(name)
+	if _, ok := csharpKeywords[name]; ok || name == "found" {
+		name += "_"
+	}
+
+	return name
+}
+
+// csharpTuple returns a value tuple of values, or the value itself if there is only one
+func csharpTuple(values []string) string {
+	if len(values) == 1 {
+		return values[0]
+	}
+
+	return "(" + strings.Join(values, ", ") + ")"
+}
+
+// csharpQuote returns a C# string literal, control characters are escaped
+func csharpQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04x`, r)
+				continue
+			}
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+
+	return sb.String()
+}
+
+// WriteCSharp output C# loader to "csharp_path/sheetname.loader.cs"
+func (pr *ProtoSheet) WriteCSharp() error {
+	src, err := pr.GenCSharp()
+	if err != nil {
+		return fmt.Errorf("generate C# loader of %s fail, %v", pr.Name, err)
+	}
+
+	return ioutil.WriteFile(filepath.Join(pr.cfg.CSharpOutPath, strings.ToLower(pr.Name)+CSharpExt), src, 0644)
+}
+
+// writeCSharpReader output ProtoReader used by C# loaders in dir, if it is missing or outdated
+func (cv *Converter) writeCSharpReader(dir string) error {
+	cv.mutex.Lock()
+	defer cv.mutex.Unlock()
+
+	src := csharpFile("", cv.cfg.PackageName, csharpReader)
+	readerFile := filepath.Join(dir, CSharpReaderFile)
+	if raw, err := ioutil.ReadFile(readerFile); err == nil && bytes.Equal(raw, src) {
+		return nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return ioutil.WriteFile(readerFile, src, 0644)
+}
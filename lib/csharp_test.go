@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSharpNames(t *testing.T) {
+	assert.Equal(t, `"a\"b\\c\n\u0001中"`, csharpQuote("a\"b\\c\n\x01中"))
+	assert.Equal(t, "itemID", csharpParamName("ItemID"))
+	assert.Equal(t, "class_", csharpParamName("Class"))
+	assert.Equal(t, "found_", csharpParamName("Found"))
+	assert.Equal(t, "(a, b)", csharpTuple([]string{"a", "b"}))
+	assert.Equal(t, "a", csharpTuple([]string{"a"}))
+}
+
+func TestGenCSharp(t *testing.T) {
+	sheet := newTestSheet("STAGE", [][]string{
+		{"unique", "unique:key1", "unique:key1", "optional", "optional", "optional"},
+		{"uint32", "uint32", "enum:ItemQuality", "list<sint32>", "map<string, float>", "double"},
+		{"ID", "ChapterID", "Quality", "Levels", "Texts", "Rate=1.5"},
+		{"", "", "", "", "", ""},
+		{"1", "1", "Rare", "1;-2", "en:1.5", ""},
+	})
+
+	cfg := newTestConfig()
+	cfg.PackageName = "ProtobufGen"
+	pr := newProtoRow(cfg)
+	pr.enums = map[string]*Enum{"ItemQuality": newTestEnum()}
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.GenProto())
+
+	src, err := pr.GenCSharp()
+	assert.NoError(t, err)
+	code := string(src)
+	for _, line := range []string{
+		"namespace ProtobufGen\n{\n",
+		"    public enum STAGE_ItemQuality\n",
+		"        public uint ID;\n",
+		"        public STAGE_ItemQuality Quality = STAGE_ItemQuality.Common;\n",
+		"        public double Rate = 1.5d;\n",
+		"        public List<int> Levels = new List<int>();\n",
+		"        public Dictionary<string, float> Texts = new Dictionary<string, float>();\n",
+		"                        Levels.Add(r.ReadSInt32());\n",
+		"                                Levels.Add(r.ReadSInt32());\n", // packed
+		"                                        value = r.ReadFloat();\n",
+		"        public readonly Dictionary<(uint, STAGE_ItemQuality), STAGE> ByKey1 = new Dictionary<(uint, STAGE_ItemQuality), STAGE>();\n",
+		"        public static STAGE_ARRAY Load(byte[] data)\n",
+		"                array.ByKey1[(item.ChapterID, item.Quality)] = item;\n",
+		"        public STAGE GetByID(uint id)\n",
+		"        public STAGE GetByKey1(uint chapterID, STAGE_ItemQuality quality)\n",
+	} {
+		assert.Contains(t, code, line)
+	}
+}
+
+func TestWriteCSharp(t *testing.T) {
+	cfg := newTempConfig(t)
+	cfg.CSharpOutPath = t.TempDir()
+	cv := newConverter(cfg)
+	assert.NoError(t, cv.ReadSheet("Sample.xlsx", "SAMPLEONE"))
+
+	src, err := ioutil.ReadFile(filepath.Join(cfg.CSharpOutPath, "sampleone"+CSharpExt))
+	assert.NoError(t, err)
+	assert.Contains(t, string(src), "public static SAMPLEONE_ARRAY Load(byte[] data)")
+	reader, err := ioutil.ReadFile(filepath.Join(cfg.CSharpOutPath, CSharpReaderFile))
+	assert.NoError(t, err)
+	assert.Contains(t, string(reader), "public sealed class ProtoReader")
+
+	assert.NoError(t, cv.Clean())
+	files, _ := filepath.Glob(filepath.Join(cfg.CSharpOutPath, "*"))
+	assert.Empty(t, files)
+}
+
+func TestWriteCSharpIndexChanged(t *testing.T) {
+	cfg := newTempConfig(t)
+	cfg.XlsxPath = t.TempDir()
+	cfg.CSharpOutPath = t.TempDir()
+	cfg.GoOutPath = filepath.Join(t.TempDir(), "config")
+	assert.NoError(t, cfg.CheckDirs())
+	run := func(attrs string) (string, string) {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(cfg.XlsxPath, "stages.csv"), []byte(attrs+`
+uint32,uint32,uint32
+ID,ChapterID,StageID
+,,
+1,1,1
+2,1,2
+`), 0644))
+		cv := newConverter(cfg)
+		assert.NoError(t, cv.readCfgLine("STAGE stages.csv"))
+		_, err := cv.Run(Options{UseCache: true})
+		assert.NoError(t, err)
+
+		csSrc, err := ioutil.ReadFile(filepath.Join(cfg.CSharpOutPath, "stage"+CSharpExt))
+		assert.NoError(t, err)
+		goSrc, err := ioutil.ReadFile(filepath.Join(cfg.GoOutPath, "stage"+GoLoaderExt))
+		assert.NoError(t, err)
+		return string(csSrc), string(goSrc)
+	}
+
+	csSrc, goSrc := run("unique,optional,optional")
+	assert.NotContains(t, csSrc, "GetByPos")
+	assert.NotContains(t, goSrc, "GetByPos")
+
+	// both loaders get the index of composite key, though proto is the same
+	csSrc, goSrc = run("unique,unique:pos,unique:pos")
+	assert.Contains(t, csSrc, "GetByPos")
+	assert.Contains(t, goSrc, "GetByPos")
+}
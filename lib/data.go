@@ -14,12 +14,16 @@ import (
 	"sync"
 
 	"github.com/golang/protobuf/proto"
-	"github.com/tealeg/xlsx"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 var (
 	ErrRequiredFieldEmpty = errors.New("required field is empty")
 	ErrFormatInvalid      = errors.New("invalid cell value")
+	ErrDuplicateHead      = errors.New("duplicate field name")
+	ErrDuplicateUnique    = errors.New("duplicate unique value")
 )
 
 // ProtoSheet is used to generate proto file
@@ -27,7 +31,12 @@ var (
 type ProtoSheet struct {
 	ProtoIn
 	ProtoOut
-	buf *proto.Buffer
+	buf    *proto.Buffer
+	cfg    *Config
+	enums  map[string]*Enum // enums can be used in current sheet
+	refs   []*RefCell       // cells refer to other sheets
+	lock   *MessageLock     // field numbers of previous runs, nil if field numbers are in column order
+	target string           // export target of output files, empty if all columns are exported
 
 	mutex sync.RWMutex
 }
@@ -39,18 +48,31 @@ type ProtoIn struct {
 	repeats    []*Repeat
 	optStructs []*OptStruct
 	dataHash   []byte
-	fieldMap   map[string]int                 // map[fieldName]index in vars/opt structs/repeats
-	dupMap     map[string]struct{}            // map[fieldName] to check if field name has been used in current sheet
-	uniqueMap  map[string]map[string]struct{} // map[fieldName][uniqueName] to check if unique type of variant has duplicates
+	fieldMap   map[string]int               // map[fieldName]index in vars/opt structs/repeats
+	dupMap     map[string]struct{}          // map[fieldName] to check if field name has been used in current sheet
+	uniqueMap  map[string]map[string]rowPos // map[fieldName][uniqueName] to check if unique type of variant has duplicates
+	keyMap     map[string]map[string]rowPos // map[keyName][tuple] to check if composite unique keys have duplicates
+	uniqueKeys []*UniqueKey                 // composite unique keys of current sheet
+	curRow     rowPos                       // position of the row being read
+	stream     *dataStream                  // not nil if rows are written to data file as they are read
+	exportCol  int                          // index of ExportColumn in current sheet, -1 if not exists
+	rowTargets [][]string                   // export targets of each row in data, nil for all targets
+	skipped    int                          // count of disabled rows
 }
 
 // ProtoOut controls how to output proto file
 type ProtoOut struct {
 	varIdx    int
 	tabCount  int
+	curIndent string // indent of current line, INDENT repeated tabCount times
 	isProto3  bool
 	protoHash []byte
 	outProto  []string
+	comments  map[string]string // map[source code path]comment, used to render proto file
+
+	fileDesc  *descriptorpb.FileDescriptorProto
+	msgDesc   protoreflect.MessageDescriptor // sheet message, used to encode rows
+	arrayDesc protoreflect.MessageDescriptor // XXX_ARRAY message, used to decode data
 }
 
 // Row index in sheet
@@ -68,6 +90,7 @@ const (
 	Opt     = "optional"
 	Rep     = "repeated"
 	OptStru = "optional_struct"
+	Map     = "map" // followed by key and value columns in turn
 	Unique  = "unique"
 )
 
@@ -77,6 +100,10 @@ type Val struct {
 	proto2Type      string
 	typ             string
 	defaultValueStr string
+	uniqueKey       string      // name of composite unique key the val belongs to
+	enum            *Enum       // not nil if typ is an enum
+	ref             *Ref        // not nil if val refers to a unique field in another sheet
+	constraint      *Constraint // not nil if type has a range or pattern
 }
 
 // OptStruct is a struct contains one or more variants
@@ -92,25 +119,33 @@ type Repeat struct {
 	repeatIdx int // proto repeat index
 	val       *Val
 	opts      *OptStruct
+	key       *Val // not nil if repeat is a map, val is map value
+	mapCols   bool // key and value columns follow the map column
+	inline    bool // all elements are in one cell, see isInlineType
+	required  bool // inline cell can not be empty
 }
 
 // CommonInfo is common info for val, opt struct and repeat
 type CommonInfo struct {
 	name      string
 	comment   string
-	colIdx    int // position of column
-	fieldNum  int // proto index
-	curLength int // use for counter
-	maxLength int // use for proto define
+	colIdx    int      // position of column
+	fieldNum  int      // proto index
+	curLength int      // use for counter
+	maxLength int      // use for proto define
+	targets   []string // export targets, empty for all targets
 }
 
-func newProtoRow() *ProtoSheet {
+func newProtoRow(cfg *Config) *ProtoSheet {
 	pr := new(ProtoSheet)
+	pr.cfg = cfg
 	pr.fieldMap = make(map[string]int)
 	pr.isProto3 = cfg.UseProto3
 	pr.varIdx = 1
 	pr.buf = proto.NewBuffer([]byte{})
-	pr.uniqueMap = make(map[string]map[string]struct{})
+	pr.uniqueMap = make(map[string]map[string]rowPos)
+	pr.keyMap = make(map[string]map[string]rowPos)
+	pr.exportCol = -1
 	return pr
 }
 
@@ -129,98 +164,240 @@ func newOptStruct() *OptStruct {
 }
 
 // ReadSheet Read data pair from *.config
-func ReadSheet(fileName, sheetName string) error {
-	sheets := make([]*xlsx.Sheet, 0)
+func (cv *Converter) ReadSheet(fileName, sheetName string) error {
+	_, err := cv.readSheet(fileName, sheetName)
+	return err
+}
+
+// readSheet read sheets in a config line, returns the count of disabled rows
+func (cv *Converter) readSheet(fileName, sheetName string) (int, error) {
+	sheets := make([]Sheet, 0)
 	var preName string
 
 	files := strings.Split(fileName, "|")
 	if len(files) > 1 {
 		preName = sheetName
 	}
+	sheetNames := strings.Split(sheetName, ",")
+	if len(sheetNames) > 1 {
+		sections := strings.Split(fileName, ".")
+		preName = sections[0]
+	}
+
+	if cv.cfg.isStreamSheet(sheetNames) {
+		return cv.readStream(fileName, preName, files, sheetNames)
+	}
 
 	for _, fn := range files {
 		fmt.Printf("reading %v for sheets %v\n", fn, sheetName)
 
-		xlsxFullName := filepath.Join(cfg.XlsxPath, fn+cfg.XlsxExt)
-		if _, err := os.Stat(xlsxFullName); os.IsNotExist(err) {
-			return fmt.Errorf("file %s does not exists", fn)
+		fullName := cv.cfg.sourcePath(fn)
+		if _, err := os.Stat(fullName); os.IsNotExist(err) {
+			return 0, fmt.Errorf("file %s does not exists", fn)
 		}
 
-		xlsxFile, err := xlsx.OpenFile(xlsxFullName)
+		source, err := cv.sources.open(fullName)
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 		// Verify all sheets exists in file
-		sheetNames := strings.Split(sheetName, ",")
-		if len(sheetNames) > 1 {
-			sections := strings.Split(fileName, ".")
-			preName = sections[0]
-		}
-
 		for _, sheetName := range sheetNames {
-			xlsxSheet, ok := xlsxFile.Sheet[sheetName]
+			sheet, ok := source.Sheet(sheetName)
 			if !ok {
-				return fmt.Errorf("xlsx file %s does not contain sheet %s", fn, sheetName)
+				return 0, fmt.Errorf("xlsx file %s does not contain sheet %s", fn, sheetName)
 			}
 
-			sheets = append(sheets, xlsxSheet)
+			sheets = append(sheets, sheet)
 		}
 	}
 
 	// Marshal data
-	if err := readSheets(preName, sheets); err != nil {
-		return err
+	skipped, err := cv.readSheets(fileName, preName, sheets)
+	if err != nil {
+		return 0, err
 	}
 
 	fmt.Printf("done for %v for sheets %v\n", fileName, sheetName)
 
-	return nil
+	return skipped, nil
 }
 
-func readSheets(preName string, sheets []*xlsx.Sheet) error {
-	pr := newProtoRow()
-
-	hasGenProto := false
+func (cv *Converter) readSheets(fileName, preName string, sheets []Sheet) (int, error) {
+	pr := newProtoRow(cv.cfg)
+	pr.enums = cv.enums
 
 	// use filename instead of sheet name if sheets are more than 1
 	if len(sheets) > 1 {
 		pr.Name = preName
+	} else if len(sheets) == 1 {
+		pr.Name = strings.TrimSpace(sheets[0].Name())
 	}
+	pr.lock = cv.messageLock(pr.Name)
 
 	for _, sheet := range sheets {
-		if sheet.MaxRow < RowData {
-			return fmt.Errorf("sheet %v contains no data", sheet)
+		if sheet.MaxRow() < RowData {
+			return 0, fmt.Errorf("sheet %v contains no data", sheet.Name())
 		}
 
 		// update head for each sheet, avoiding empty columns changes the col index
-		pr.updateHeads(sheet)
+		if err := pr.updateHeads(sheet); err != nil {
+			return 0, toSheetError("", sheet.Name(), err)
+		}
+
+		if err := pr.readData(sheet); err != nil {
+			return 0, toSheetError("", sheet.Name(), err)
+		}
+	}
+
+	return cv.finishSheets(fileName, pr)
+}
+
+// finishSheets generate proto after all sheets of a message are read, and write output files
+func (cv *Converter) finishSheets(fileName string, pr *ProtoSheet) (int, error) {
+	// check if proto need update
+	// proto is generated after all heads are read, so it always matches data
+	pr.reserveUnusedFields()
+	if err := pr.GenProto(); err != nil {
+		return 0, err
+	}
+	pr.ProtoHash()
+
+	cv.updateRefInfo(pr.Name, pr.refInfo(fileName))
+
+	// each target has its own proto and data, which only contain columns for the target
+	outputs := []*ProtoSheet{pr}
+	if len(cv.cfg.Targets) > 0 {
+		outputs = outputs[:0]
+		for _, target := range cv.cfg.Targets {
+			tp, err := pr.forTarget(target)
+			if err != nil {
+				return 0, err
+			}
+			outputs = append(outputs, tp)
+		}
+	}
+
+	for _, out := range outputs {
+		if err := cv.writeOutput(out); err != nil {
+			cv.forgetOutput(out)
+			return 0, err
+		}
+	}
+
+	if !cv.dryRun {
+		cv.updateMessageLock(pr.Name, pr.lock)
+	}
+
+	return pr.skipped, nil
+}
+
+// writeOutput write proto, descriptor, data, json, lua, loader and text files of a sheet if they changed
+func (cv *Converter) writeOutput(pr *ProtoSheet) error {
+	// check before previous descriptor is overwritten, and before proto hash is saved in cache
+	if err := pr.checkCompat(cv.strictCompat); err != nil {
+		return err
+	}
+
+	protoChanged := cv.IsProtoChanged(pr)
+	dataChanged := cv.IsDataChanged(pr)
+	if cv.dryRun {
+		return nil
+	}
+
+	// rows are compared with previous files before they are overwritten
+	// rows of streamed sheets are not kept in memory, so they are not compared
+	if dataChanged && cv.changes != nil && pr.stream == nil {
+		change, err := pr.diffRows()
+		if err != nil {
+			return fmt.Errorf("compare rows of %s fail, %v", pr.outputName(), err)
+		}
+		cv.addRowChange(change)
+	}
 
-		// check if proto need update
-		// only hash head which will be used to generate proto file
-		if !hasGenProto {
-			pr.GenProto()
-			pr.ProtoHash()
-			hasGenProto = true
+	if protoChanged {
+		if err := pr.WriteProto(); err != nil {
+			return err
 		}
+	}
 
-		pr.readData(sheet)
+	// descriptor set is written with proto file, also write it if it is missing
+	descFile := filepath.Join(pr.cfg.ProtoOutPath, strings.ToLower(pr.Name)+DescriptorExt)
+	if _, err := os.Stat(descFile); protoChanged || os.IsNotExist(err) {
+		if err := pr.WriteDescriptor(); err != nil {
+			return err
+		}
 	}
 
-	if IsProtoChanged(pr) {
-		pr.WriteProto()
+	if dataChanged {
+		if err := pr.WriteData(); err != nil {
+			return err
+		}
+	}
+
+	// json is optional, also write it if json file is missing
+	if pr.cfg.JSONOutPath != "" && pr.stream == nil {
+		jsonFile := filepath.Join(pr.cfg.JSONOutPath, strings.ToLower(pr.Name)+pr.cfg.JSONOutExt)
+		if _, err := os.Stat(jsonFile); dataChanged || os.IsNotExist(err) {
+			if err := pr.WriteJSON(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// lua is optional, also write it if lua file is missing
+	if pr.cfg.LuaOutPath != "" && pr.stream == nil {
+		luaFile := filepath.Join(pr.cfg.LuaOutPath, strings.ToLower(pr.Name)+LuaExt)
+		if _, err := os.Stat(luaFile); dataChanged || os.IsNotExist(err) {
+			if err := pr.WriteLua(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// loaders are generated from proto, indexes and package, also write them if they are missing
+	var loaderChanged bool
+	if pr.cfg.GoOutPath != "" || pr.cfg.CSharpOutPath != "" {
+		loaderChanged = cv.IsLoaderChanged(pr)
+	}
+
+	if pr.cfg.GoOutPath != "" {
+		goFile := filepath.Join(pr.cfg.GoOutPath, strings.ToLower(pr.Name)+GoLoaderExt)
+		if _, err := os.Stat(goFile); loaderChanged || os.IsNotExist(err) {
+			if err := pr.WriteGo(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if pr.cfg.CSharpOutPath != "" {
+		if err := cv.writeCSharpReader(pr.cfg.CSharpOutPath); err != nil {
+			return err
+		}
+		csFile := filepath.Join(pr.cfg.CSharpOutPath, strings.ToLower(pr.Name)+CSharpExt)
+		if _, err := os.Stat(csFile); loaderChanged || os.IsNotExist(err) {
+			if err := pr.WriteCSharp(); err != nil {
+				return err
+			}
+		}
 	}
 
-	if pr != nil && IsDataChanged(pr) {
-		pr.WriteData()
+	// text dump is optional, also write it if text file is missing
+	if pr.cfg.TextDump && pr.stream == nil {
+		textFile := filepath.Join(pr.cfg.DataOutPath, strings.ToLower(pr.Name)+TextExt)
+		if _, err := os.Stat(textFile); dataChanged || os.IsNotExist(err) {
+			if err := pr.WriteText(); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
-func (pr *ProtoSheet) updateHeads(sheet *xlsx.Sheet) {
+func (pr *ProtoSheet) updateHeads(sheet Sheet) error {
 	if pr.Name == "" {
-		pr.Name = strings.TrimSpace(sheet.Name)
+		pr.Name = strings.TrimSpace(sheet.Name())
 	}
 
 	pr.resetAllIndex() // clear previous sheet data
@@ -228,32 +405,84 @@ func (pr *ProtoSheet) updateHeads(sheet *xlsx.Sheet) {
 	var curRepeat *Repeat
 	var curOptS *OptStruct
 
-	for colIdx := 0; colIdx < sheet.MaxCol; colIdx++ {
-		headType := sheet.Cell(RowAttr, colIdx).Value
+	for colIdx := 0; colIdx < sheet.MaxCol(); colIdx++ {
+		headType, targets := splitAttr(sheet.Cell(RowAttr, colIdx))
 
+		if strings.TrimSpace(sheet.Cell(RowID, colIdx)) == ExportColumn && curOptS == nil && curRepeat == nil {
+			pr.exportCol = colIdx
+			continue
+		}
 		if strings.TrimSpace(headType) == "" {
 			continue
 		}
+		if err := pr.checkTargets(colIdx, targets); err != nil {
+			return err
+		}
 
+		headType, uniqueKey := splitUniqueKey(headType)
 		switch headType {
 		case Req, Opt, Unique:
 			val := new(Val)
 			val.colIdx = colIdx
 			val.proto2Type = headType
-			val.typ = sheet.Cell(RowType, colIdx).Value
-			val.name = sheet.Cell(RowID, colIdx).Value
+			if uniqueKey != "" {
+				if curOptS != nil || curRepeat != nil {
+					return &SheetError{Col: colIdx + 1, Err: fmt.Errorf("%w %s, can not be used in optional struct or repeat", ErrUniqueKeyInvalid, uniqueKey)}
+				}
+				val.proto2Type = Opt // each column of a composite key can have duplicates
+				val.uniqueKey = uniqueKey
+			}
+			val.targets = targets
+			val.name = sheet.Cell(RowID, colIdx)
+			if err := val.parseType(sheet.Cell(RowType, colIdx)); err != nil {
+				return err
+			}
 			val.defaultValueStr = "0"
-			if val.typ == "string" {
+			switch val.typ {
+			case "string":
 				val.defaultValueStr = `""`
+			case "bool":
+				val.defaultValueStr = "false"
+			}
+			if strings.HasPrefix(val.typ, EnumTypePrefix) {
+				val.defaultValueStr = "" // first enum value
 			}
 			// If val name has default value
 			if strings.Contains(val.name, "=") {
 				parts := strings.Split(val.name, "=")
 				val.name, val.defaultValueStr = parts[0], parts[1]
 			}
-			val.comment = sheet.Cell(RowComment, colIdx).Value
+			val.comment = sheet.Cell(RowComment, colIdx)
+			if err := pr.resolveEnum(val); err != nil {
+				return err
+			}
+
+			if isInlineType(val.typ) {
+				if curOptS != nil || curRepeat != nil {
+					return &SheetError{Col: colIdx + 1, Field: val.name, Err: fmt.Errorf("%w, %s can not be used in optional struct or repeat", ErrInlineTypeInvalid, val.typ)}
+				}
+				repeat, err := pr.newInlineRepeat(val)
+				if err != nil {
+					return err
+				}
+				if err := pr.updateRepeat(repeat); err != nil {
+					return err
+				}
+				continue
+			}
 
 			switch {
+			case curRepeat != nil && curRepeat.mapCols: // Check map
+				if curOptS != nil {
+					return &SheetError{Col: colIdx + 1, Field: val.name, Err: errors.New("sheet struct invalid, optional struct can not be used in map")}
+				}
+				curRepeat.addMapColumn(val)
+				if curRepeat.curLength <= 0 {
+					if err := pr.updateRepeat(curRepeat); err != nil {
+						return err
+					}
+					curRepeat = nil
+				}
 			case curOptS != nil: // Check opts
 				if len(curOptS.fields) < curOptS.maxLength {
 					val.fieldNum = curOptS.messageIdx
@@ -274,19 +503,23 @@ func (pr *ProtoSheet) updateHeads(sheet *xlsx.Sheet) {
 						curRepeat.curLength--
 
 						if curRepeat.curLength <= 0 {
-							pr.updateRepeat(curRepeat)
+							if err := pr.updateRepeat(curRepeat); err != nil {
+								return err
+							}
 							curRepeat = nil
 						}
 					} else {
 						fmt.Printf("%v col %v curOptS %+v\n", pr.Name, colIdx, curOptS)
-						pr.updateOptStruct(curOptS)
+						if err := pr.updateOptStruct(curOptS); err != nil {
+							return err
+						}
 					}
 
 					curOptS = nil
 				}
 			case curRepeat != nil && curOptS == nil: // Check repeat variant
 				if curRepeat.opts != nil {
-					panic(fmt.Errorf("sheet struct invalid, max repeat value exceed"))
+					return &SheetError{Col: colIdx + 1, Field: val.name, Err: errors.New("sheet struct invalid, max repeat value exceed")}
 				}
 
 				if curRepeat.val == nil {
@@ -300,77 +533,123 @@ func (pr *ProtoSheet) updateHeads(sheet *xlsx.Sheet) {
 				curRepeat.curLength--
 
 				if curRepeat.curLength <= 0 {
-					pr.updateRepeat(curRepeat)
+					if err := pr.updateRepeat(curRepeat); err != nil {
+						return err
+					}
 					curRepeat = nil
 				}
 			default: // update val
-				pr.updateVal(val)
+				if err := pr.updateVal(val); err != nil {
+					return err
+				}
 			}
 		case Rep:
 			curRepeat = newRepeat()
 			curRepeat.colIdx = colIdx
-			curRepeat.maxLength, _ = sheet.Cell(RowType, colIdx).Int()
+			curRepeat.targets = targets
+			curRepeat.maxLength, _ = parseInt(sheet.Cell(RowType, colIdx))
 			curRepeat.curLength = curRepeat.maxLength
+		case Map:
+			curRepeat = newRepeat()
+			curRepeat.colIdx = colIdx
+			curRepeat.targets = targets
+			curRepeat.name = sheet.Cell(RowID, colIdx)
+			curRepeat.comment = sheet.Cell(RowComment, colIdx)
+			curRepeat.maxLength, _ = parseInt(sheet.Cell(RowType, colIdx))
+			curRepeat.curLength = curRepeat.maxLength * 2 // key and value
+			curRepeat.mapCols = true
 		case OptStru:
 			curOptS = newOptStruct()
 			curOptS.colIdx = colIdx
-			curOptS.name = sheet.Cell(RowID, colIdx).Value
-			curOptS.comment = sheet.Cell(RowComment, colIdx).Value
-			curOptS.maxLength, _ = sheet.Cell(RowType, colIdx).Int()
+			curOptS.targets = targets
+			curOptS.name = sheet.Cell(RowID, colIdx)
+			curOptS.comment = sheet.Cell(RowComment, colIdx)
+			curOptS.maxLength, _ = parseInt(sheet.Cell(RowType, colIdx))
 			curOptS.curLength = curOptS.maxLength
 		}
 	}
 
 	// sometimes people will not full fill all repeat structs they designed...
 	if curRepeat != nil {
-		pr.updateRepeat(curRepeat)
+		if err := pr.updateRepeat(curRepeat); err != nil {
+			return err
+		}
 		curRepeat = nil
 	}
 
+	if err := pr.checkEnumValues(); err != nil {
+		return err
+	}
+
 	/*
 		// Debug
-		fmt.Printf("sheetName %v\n", sheet.Name)
+		fmt.Printf("sheetName %v\n", sheet.Name())
 		for _, val := range pr.vars {
 			fmt.Printf("val %+v\n", val)
 		}
 		fmt.Printf("--------------------------")
 	*/
+
+	return nil
 }
 
-func (pr *ProtoSheet) checkDupHead(info *CommonInfo) {
+// parseType read type and annotations from type cell
+func (val *Val) parseType(typeCell string) error {
+	var annotations []string
+	val.typ, annotations = splitType(typeCell)
+	if err := val.parseConstraint(); err != nil {
+		return err
+	}
+
+	for _, annotation := range annotations {
+		switch {
+		case strings.HasPrefix(annotation, RefPrefix):
+			ref, err := parseRef(annotation)
+			if err != nil {
+				return &SheetError{Col: val.colIdx + 1, Field: val.name, Err: err}
+			}
+			val.ref = ref
+		default:
+			return &SheetError{Col: val.colIdx + 1, Field: val.name, Err: fmt.Errorf("unknown type annotation %s", annotation)}
+		}
+	}
+
+	return nil
+}
+
+func (pr *ProtoSheet) checkDupHead(info *CommonInfo) error {
 	pr.mutex.Lock()
 	defer pr.mutex.Unlock()
 
 	if _, ok := pr.dupMap[info.name]; ok {
-		panic(fmt.Errorf("Verify failed! Duplicate name %v found in column %v of %v!!!\n", info.name, info.colIdx, pr.Name))
+		return &SheetError{Col: info.colIdx + 1, Field: info.name, Err: ErrDuplicateHead}
 	}
 	pr.dupMap[info.name] = struct{}{}
+
+	return nil
 }
 
 func (pr *ProtoSheet) checkDupUnique(varName, varValue string) error {
 	pr.mutex.Lock()
 	defer pr.mutex.Unlock()
 
-	if _, ok := pr.uniqueMap[varName]; !ok {
-		pr.uniqueMap[varName] = make(map[string]struct{}, 256)
+	if pos, ok := checkDup(pr.uniqueMap, varName, varValue, pr.curRow); !ok {
+		return fmt.Errorf("%w %s, first in %s", ErrDuplicateUnique, varValue, pos.describe(pr.curRow))
 	}
 
-	if _, ok := pr.uniqueMap[varName][varValue]; ok {
-		return fmt.Errorf("duplicate unique %s in %s", varValue, varName)
-	}
-
-	pr.uniqueMap[varName][varValue] = struct{}{}
 	return nil
 }
 
 // updateVal if a variable is already in ProtoSheet, update its value, else add it
-func (pr *ProtoSheet) updateVal(val *Val) {
+func (pr *ProtoSheet) updateVal(val *Val) error {
 	if val.name == "" {
 		log.Printf("val %+v without name\n", val)
-		return
+		return nil
 	}
 
-	pr.checkDupHead(&val.CommonInfo)
+	if err := pr.checkDupHead(&val.CommonInfo); err != nil {
+		return err
+	}
 
 	pr.mutex.Lock()
 	defer pr.mutex.Unlock()
@@ -391,19 +670,23 @@ func (pr *ProtoSheet) updateVal(val *Val) {
 		val.fieldNum = pr.varIdx
 		pr.varIdx++
 	}
+
+	return nil
 }
 
-func (pr *ProtoSheet) updateOptStruct(optS *OptStruct) {
+func (pr *ProtoSheet) updateOptStruct(optS *OptStruct) error {
 	if optS == nil {
 		log.Printf("optional struct %+v nil\n", optS)
-		return
+		return nil
 	}
 	if optS.name == "" {
 		log.Printf("optional struct %+v without name\n", optS)
-		return
+		return nil
 	}
 
-	pr.checkDupHead(&optS.CommonInfo)
+	if err := pr.checkDupHead(&optS.CommonInfo); err != nil {
+		return err
+	}
 
 	pr.mutex.Lock()
 	defer pr.mutex.Unlock()
@@ -417,7 +700,7 @@ func (pr *ProtoSheet) updateOptStruct(optS *OptStruct) {
 		} else {
 			fmt.Printf("sheet %v opt struct length %v is not equal as others %v\n",
 				pr.Name, optS.maxLength, pr.optStructs[idx].maxLength)
-			return
+			return nil
 		}
 	} else {
 		optS.fieldNum = pr.varIdx
@@ -426,26 +709,34 @@ func (pr *ProtoSheet) updateOptStruct(optS *OptStruct) {
 		pr.optStructs = append(pr.optStructs, optS)
 		pr.fieldMap[optS.name] = newIdx
 	}
+
+	return nil
 }
 
 // updateRepeat if a repeat has same optional struct name and maxLength as in ProtoSheet, update it, else add it
-func (pr *ProtoSheet) updateRepeat(repeat *Repeat) {
-	if repeat.opts != nil {
+func (pr *ProtoSheet) updateRepeat(repeat *Repeat) error {
+	switch {
+	case repeat.mapCols && (repeat.key == nil || repeat.val == nil):
+		return &SheetError{Col: repeat.colIdx + 1, Field: repeat.name, Err: errors.New("sheet struct invalid, map needs key and value columns")}
+	case repeat.inline, repeat.mapCols: // named by column
+	case repeat.opts != nil:
 		repeat.name = repeat.opts.name
 		repeat.comment = repeat.opts.comment
-	} else if repeat.val != nil {
+	case repeat.val != nil:
 		repeat.name = repeat.val.name
-	} else {
+	default:
 		log.Printf("[updateRepeat] empty repeat\n")
-		return
+		return nil
 	}
 
 	if repeat.name == "" {
 		log.Printf("[updateRepeat] empty repeat name from val or opt struct/n")
-		return
+		return nil
 	}
 
-	pr.checkDupHead(&repeat.CommonInfo)
+	if err := pr.checkDupHead(&repeat.CommonInfo); err != nil {
+		return err
+	}
 
 	pr.mutex.Lock()
 	defer pr.mutex.Unlock()
@@ -459,7 +750,7 @@ func (pr *ProtoSheet) updateRepeat(repeat *Repeat) {
 		} else {
 			fmt.Printf("sheet %v repeat length %v is not equal as others %v\n",
 				pr.Name, repeat.maxLength, pr.repeats[idx].maxLength)
-			return
+			return nil
 		}
 	} else {
 		// add
@@ -473,11 +764,14 @@ func (pr *ProtoSheet) updateRepeat(repeat *Repeat) {
 	if repeat.val != nil {
 		repeat.val.fieldNum = repeat.fieldNum
 	}
+
+	return nil
 }
 
 // resetAllIndex will set all variables including which are inside repeat structure to -1
 func (pr *ProtoSheet) resetAllIndex() {
 	pr.dupMap = make(map[string]struct{})
+	pr.exportCol = -1
 
 	for _, val := range pr.vars {
 		val.colIdx = -1
@@ -496,41 +790,84 @@ func (pr *ProtoSheet) resetAllIndex() {
 	}
 }
 
-func (pr *ProtoSheet) readData(sheet *xlsx.Sheet) {
-	for i := RowData; i < sheet.MaxRow; i++ {
-		if row := sheet.Rows[i]; len(row.Cells) != 0 && strings.TrimSpace(row.Cells[0].Value) != "" {
-			rawRowData := pr.readRow(row)
-			if len(rawRowData) != 0 {
-				// Add Tag
-				err := pr.buf.EncodeVarint(uint64(10)) // (1 << 3) | 2 = 10
-				if err != nil {
-					log.Fatal(err)
-				}
-				// Add contents
-				err = pr.buf.EncodeRawBytes(rawRowData)
-				if err != nil {
-					log.Fatal(err)
-				}
-			}
+func (pr *ProtoSheet) readData(sheet Sheet) error {
+	// heads may be changed by current sheet
+	if err := pr.BuildDescriptor(); err != nil {
+		return err
+	}
+	pr.updateUniqueKeys()
+
+	for i := RowData; i < sheet.MaxRow(); i++ {
+		if err := pr.readDataRow(sheet.Name(), i, sheet.Row(i)); err != nil {
+			return err
 		}
 	}
 
 	pr.DataHash()
+
+	return nil
 }
 
-func (rp *Repeat) getCount(row *xlsx.Row) int {
+// readDataRow encode the ith row of a sheet and add it to buf, rows with empty first cell are ignored
+func (pr *ProtoSheet) readDataRow(sheetName string, i int, row []string) error {
+	if len(row) == 0 || strings.TrimSpace(row[0]) == "" {
+		return nil
+	}
+
+	skip, targets, err := pr.filterRow(row)
+	if err != nil {
+		se := toSheetError("", "", err)
+		se.Row = i + 1
+		return se
+	}
+	if skip {
+		pr.skipped++
+		return nil
+	}
+
+	pr.curRow = rowPos{sheet: sheetName, row: i + 1}
+	refCount := len(pr.refs)
+	rawRowData, err := pr.readRow(row)
+	if err != nil {
+		se := toSheetError("", "", err)
+		se.Row = i + 1
+		return se
+	}
+	for _, ref := range pr.refs[refCount:] {
+		ref.Row = i + 1
+	}
+	if len(rawRowData) != 0 {
+		// Add Tag
+		err := pr.buf.EncodeVarint(uint64(10)) // (1 << 3) | 2 = 10
+		if err != nil {
+			return err
+		}
+		// Add contents
+		err = pr.buf.EncodeRawBytes(rawRowData)
+		if err != nil {
+			return err
+		}
+		if pr.stream == nil { // streamed sheets can not be exported to targets
+			pr.rowTargets = append(pr.rowTargets, targets)
+		}
+	}
+
+	return nil
+}
+
+func (rp *Repeat) getCount(row []string) int {
 	// no repeat content
-	if rp.colIdx >= len(row.Cells) {
+	if rp.colIdx >= len(row) {
 		return 0
 	}
 	// not use
-	if strings.TrimSpace(row.Cells[rp.colIdx].Value) == "" {
+	if strings.TrimSpace(row[rp.colIdx]) == "" {
 		return 0
 	}
 	// not number
-	valCount, err := row.Cells[rp.colIdx].Int()
+	valCount, err := parseInt(row[rp.colIdx])
 	if err != nil {
-		log.Printf("convert %v to number fail, %v", row.Cells[rp.colIdx].Value, err)
+		log.Printf("convert %v to number fail, %v", row[rp.colIdx], err)
 		return 0
 	}
 
@@ -538,231 +875,277 @@ func (rp *Repeat) getCount(row *xlsx.Row) int {
 }
 
 // readRow Marshal a row of data into binary data
-func (pr *ProtoSheet) readRow(row *xlsx.Row) []byte {
-	rowBuff := proto.NewBuffer([]byte{})
-	var err error
-
+func (pr *ProtoSheet) readRow(row []string) ([]byte, error) {
 	// if first cell of a line is empty, ignore this line
-	if len(row.Cells) > 0 && strings.TrimSpace(row.Cells[0].Value) == "" {
-		return nil
+	if len(row) > 0 && strings.TrimSpace(row[0]) == "" {
+		return nil, nil
 	}
 
-	readval := func(idx int, val *Val, b *proto.Buffer) error {
-		var e error
-		if val.colIdx == -1 { // sheet has no field
-			e = readCell(b, val, new(xlsx.Cell))
-		} else if val.colIdx < len(row.Cells) {
-			// check unique type data is really unique
-			if val.proto2Type == Unique {
-				if err := pr.checkDupUnique(val.name, row.Cells[val.colIdx].Value); err != nil {
-					panic(err)
-				}
+	msg := dynamicpb.NewMessage(pr.msgDesc)
+
+	readval := func(val *Val, m protoreflect.Message) error {
+		if val.colIdx == -1 || val.colIdx >= len(row) { // sheet has no field or sheet cell is empty
+			return setCell(m, val, "")
+		}
+
+		// check unique type data is really unique
+		if val.proto2Type == Unique {
+			if err := pr.checkDupUnique(val.name, row[val.colIdx]); err != nil {
+				return err
 			}
-			e = readCell(b, val, row.Cells[val.colIdx]) // Variable part of data
-		} else { // sheet cell is empty
-			e = readCell(b, val, new(xlsx.Cell))
 		}
-		return e
+		err := setCell(m, val, row[val.colIdx]) // Variable part of data
+		pr.addRef(val, val.colIdx, row[val.colIdx])
+		return err
 	}
 
-	for i, val := range pr.vars {
-		err = readval(i, val, rowBuff)
-		if err != nil {
-			log.Fatalf("readCell to val %+v failed, %v", val, err)
+	for _, val := range pr.vars {
+		if err := readval(val, msg); err != nil {
+			return nil, &SheetError{Col: val.colIdx + 1, Field: val.name, Err: err}
 		}
 	}
+	if err := pr.checkUniqueKeys(row); err != nil {
+		return nil, err
+	}
 
 	for _, optS := range pr.optStructs {
-		// Tag
-		err := rowBuff.EncodeVarint(uint64((optS.fieldNum << 3) | 2)) // Add Repeat Tag
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// vals
-		fieldBuff := proto.NewBuffer([]byte{})
-		for i, val := range optS.fields {
-			err = readval(i, val, fieldBuff)
-			if err != nil {
+		field := msg.Mutable(fieldByNum(msg, optS.fieldNum)).Message()
+		for _, val := range optS.fields {
+			if err := readval(val, field); err != nil {
+				if errors.Is(err, ErrConstraintViolated) {
+					return nil, &SheetError{Col: val.colIdx + 1, Field: optS.name + "." + val.name, Err: err}
+				}
 				log.Printf("readCell to val %v in opt struct %+v failed, %v", val, optS, err)
 			}
 		}
-
-		err = rowBuff.EncodeRawBytes(fieldBuff.Bytes())
-		if err != nil {
-			log.Fatal(err)
-		}
 	}
 
 	for _, repeat := range pr.repeats {
 		if repeat.colIdx == -1 {
 			continue
 		}
+		if repeat.inline {
+			var cell string
+			if len(row) > repeat.colIdx {
+				cell = row[repeat.colIdx]
+			}
+			if err := pr.readInline(msg, repeat, cell); err != nil {
+				return nil, &SheetError{Col: repeat.colIdx + 1, Field: repeat.name, Err: err}
+			}
+			continue
+		}
+		if repeat.mapCols {
+			if err := pr.readMap(msg, repeat, row); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		// read the value of copy number
 		if rowCount := repeat.getCount(row); rowCount > 0 {
-			// repeat with struct, [RepeatTag][Tag1][Value1][Tag2]Value2]...
+			// repeat with struct, each struct is an element in list
 			if repeat.opts != nil {
-				fieldBuff := proto.NewBuffer([]byte{})
+				list := msg.Mutable(fieldByNum(msg, repeat.fieldNum)).List()
 				for count := 0; count < rowCount; count++ {
-					fieldBuff.Reset()
-
-					err := rowBuff.EncodeVarint(uint64((repeat.fieldNum << 3) | 2)) // Add Repeat Tag
-					if err != nil {
-						log.Fatal(err)
-					}
+					elem := list.NewElement()
 					for _, val := range repeat.opts.fields {
+						// next variable position = current position + field length + 1
+						colIdx := val.colIdx + count*(repeat.opts.maxLength+1)
 						// if the rest of a row is blank
-						cell := new(xlsx.Cell)
-						if len(row.Cells) > val.colIdx+count*(repeat.opts.maxLength+1) {
-							cell = row.Cells[val.colIdx+count*(repeat.opts.maxLength+1)]
+						var cell string
+						if len(row) > colIdx {
+							cell = row[colIdx]
 						}
-						err := readCell(fieldBuff, val, cell) // next variable position = current position + field length + 1
-						if err != nil {
+						if err := setCell(elem.Message(), val, cell); err != nil {
+							if errors.Is(err, ErrConstraintViolated) {
+								return nil, &SheetError{Col: colIdx + 1, Field: repeat.name + "." + val.name, Err: err}
+							}
 							log.Printf("readCell to repeat %+v val %+v failed, %v", repeat, val, err)
 						}
+						pr.addRef(val, colIdx, cell)
 					}
-
-					err = rowBuff.EncodeRawBytes(fieldBuff.Bytes())
-					if err != nil {
-						log.Fatal(err)
-					}
+					list.Append(elem)
 				}
-			} else if repeat.val != nil { // repeat without struct [Tag][Value][Tag][Value]...
+			} else if repeat.val != nil { // repeat without struct, each value is an element in list
 				for count := 0; count < rowCount; count++ {
-					cell := new(xlsx.Cell)
-					if len(row.Cells) > repeat.colIdx+count+1 {
-						cell = row.Cells[repeat.colIdx+count+1]
+					var cell string
+					if len(row) > repeat.colIdx+count+1 {
+						cell = row[repeat.colIdx+count+1]
 					}
 
-					err := readCell(rowBuff, repeat.val, cell) // next variable position = current position + field length + 1
-					if err != nil {
+					if err := setCell(msg, repeat.val, cell); err != nil {
+						if errors.Is(err, ErrConstraintViolated) {
+							return nil, &SheetError{Col: repeat.colIdx + count + 2, Field: repeat.name, Err: err}
+						}
 						log.Printf("readCell to repeat %+v val %+v failed, %v", repeat, repeat.val, err)
 					}
+					pr.addRef(repeat.val, repeat.colIdx+count+1, cell)
 				}
 			}
 		}
 	}
 
-	return rowBuff.Bytes()
+	return marshalOptions.Marshal(msg)
+}
+
+// fieldByNum returns field descriptor of a message by field number
+func fieldByNum(m protoreflect.Message, fieldNum int) protoreflect.FieldDescriptor {
+	return m.Descriptor().Fields().ByNumber(protoreflect.FieldNumber(fieldNum))
+}
+
+// setCell set value of a cell to field of message, or append it if field is repeated
+func setCell(m protoreflect.Message, val *Val, cell string) error {
+	v, ok, err := readCell(val, cell)
+	if err != nil || !ok {
+		return err
+	}
+
+	field := fieldByNum(m, val.fieldNum)
+	if field.IsList() {
+		m.Mutable(field).List().Append(v)
+	} else {
+		m.Set(field, v)
+	}
+
+	return nil
+}
+
+// readCell convert a cell to proto value according to var type, returns false if cell is empty
+// constraint of val is checked if cell is not empty
+func readCell(val *Val, cell string) (protoreflect.Value, bool, error) {
+	v, ok, err := parseCell(val, cell)
+	if err != nil || !ok || val.constraint == nil {
+		return v, ok, err
+	}
+	if err := val.constraint.check(v); err != nil {
+		return protoreflect.Value{}, false, err
+	}
+
+	return v, true, nil
 }
 
-// readCell add "Tag - Value" or "Tag - Length - Value" to buffer according to var type
-func readCell(b *proto.Buffer, val *Val, cell *xlsx.Cell) error {
-	if strings.TrimSpace(cell.Value) == "" {
+func parseCell(val *Val, cell string) (protoreflect.Value, bool, error) {
+	if strings.TrimSpace(cell) == "" {
 		if val.proto2Type == Req {
-			return ErrRequiredFieldEmpty
+			return protoreflect.Value{}, false, ErrRequiredFieldEmpty
 		}
-		return nil
+		return protoreflect.Value{}, false, nil
+	}
+
+	if val.enum != nil {
+		enumVal, err := val.enum.parse(cell)
+		if err != nil {
+			return protoreflect.Value{}, false, err
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(enumVal)), true, nil
 	}
 
 	switch val.typ {
-	case "int32", "int64", "uint32", "uint64":
-		err := b.EncodeVarint(uint64((val.fieldNum << 3) | proto.WireVarint))
+	case "int64", "sint64":
+		// parsed as integer so values beyond 2^53 are exact
+		intVal, err := strconv.ParseInt(strings.TrimSpace(cell), 10, 64)
 		if err != nil {
-			return err
+			return protoreflect.Value{}, false, ErrFormatInvalid
 		}
-		intVal, err := cell.Int()
+		return protoreflect.ValueOfInt64(intVal), true, nil
+	case "int32", "uint32", "uint64", "sint32":
+		if val.typ == "uint64" {
+			if uintVal, err := strconv.ParseUint(strings.TrimSpace(cell), 10, 64); err == nil {
+				return protoreflect.ValueOfUint64(uintVal), true, nil
+			}
+		}
+
+		intVal, err := parseInt(cell)
 		if err != nil {
-			return err
+			return protoreflect.Value{}, false, err
 		}
 		switch val.typ {
-		case "int32":
+		case "int32", "sint32":
 			if math.MinInt32 > intVal || intVal > math.MaxInt32 {
-				return ErrFormatInvalid
-			}
-		case "int64":
-			if strconv.Itoa(intVal) != cell.Value {
-				return ErrFormatInvalid
+				return protoreflect.Value{}, false, ErrFormatInvalid
 			}
+			return protoreflect.ValueOfInt32(int32(intVal)), true, nil
 		case "uint32":
 			if intVal < 0 || intVal > int(math.MaxUint32) {
-				return ErrFormatInvalid
+				return protoreflect.Value{}, false, ErrFormatInvalid
 			}
-		case "uint64":
-			if intVal < 0 || uint(intVal) > uint(math.MaxUint64) {
-				return ErrFormatInvalid
+			return protoreflect.ValueOfUint32(uint32(intVal)), true, nil
+		default: // uint64
+			if intVal < 0 {
+				return protoreflect.Value{}, false, ErrFormatInvalid
 			}
-		}
-		err = b.EncodeVarint(uint64(intVal))
-		if err != nil {
-			return err
-		}
-	case "sint32", "sint64":
-		err := b.EncodeVarint(uint64((val.fieldNum << 3) | proto.WireVarint))
-		if err != nil {
-			return err
-		}
-		intVal, err := cell.Int()
-		if err != nil {
-			return err
-		}
-		err = b.EncodeZigzag64(uint64(intVal))
-		if err != nil {
-			return err
+			return protoreflect.ValueOfUint64(uint64(intVal)), true, nil
 		}
 	case "float", "float32":
-		err := b.EncodeVarint(uint64((val.fieldNum << 3) | proto.WireFixed32))
-		if err != nil {
-			return err
-		}
-		floatVal, err := cell.Float()
+		floatVal, err := strconv.ParseFloat(cell, 64)
 		if err != nil {
-			return err
-		}
-		err = b.EncodeFixed32(uint64(math.Float32bits(float32(floatVal))))
-		if err != nil {
-			return err
+			return protoreflect.Value{}, false, err
 		}
+		return protoreflect.ValueOfFloat32(float32(floatVal)), true, nil
 	case "float64", "double":
-		err := b.EncodeVarint(uint64((val.fieldNum << 3) | proto.WireFixed64))
-		if err != nil {
-			return err
-		}
-		floatVal, err := cell.Float()
+		floatVal, err := strconv.ParseFloat(cell, 64)
 		if err != nil {
-			return err
+			return protoreflect.Value{}, false, err
 		}
-		err = b.EncodeFixed64(uint64(math.Float64bits(floatVal)))
+		return protoreflect.ValueOfFloat64(floatVal), true, nil
+	case "bool":
+		boolVal, err := parseBool(cell)
 		if err != nil {
-			return err
+			return protoreflect.Value{}, false, err
 		}
+		return protoreflect.ValueOfBool(boolVal), true, nil
 	case "string":
-		err := b.EncodeVarint(uint64((val.fieldNum << 3) | proto.WireBytes)) // tag
-		if err != nil {
-			return err
-		}
-		err = b.EncodeStringBytes(strings.TrimSpace(cell.Value)) // length included, and also remove extra spaces for string type
-		if err != nil {
-			return err
-		}
-	default:
-		return fmt.Errorf("invalid var type: %v\n", val.typ)
+		// remove extra spaces for string type
+		return protoreflect.ValueOfString(strings.TrimSpace(cell)), true, nil
 	}
 
-	return nil
+	return protoreflect.Value{}, false, fmt.Errorf("invalid var type: %v", val.typ)
 }
 
-func (pr *ProtoSheet) WriteData() {
-	f, err := os.Create(filepath.Join(cfg.DataOutPath, strings.ToLower(pr.Name)+cfg.DataOutExt))
-	defer f.Close()
+// parseInt accept integers and numbers with fractional part, which is dropped, e.g. "3.0" in a number cell
+func parseInt(value string) (int, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return -1, err
+	}
 
+	return int(f), nil
+}
+
+// parseBool accept TRUE/FALSE cells, 1/0 and yes/no
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "1", "yes", "y":
+		return true, nil
+	case "false", "0", "no", "n":
+		return false, nil
+	}
+
+	return false, ErrFormatInvalid
+}
+
+// WriteData output binary data to "./data/sheetname.data"
+func (pr *ProtoSheet) WriteData() error {
+	if pr.stream != nil {
+		return pr.stream.commit(filepath.Join(pr.cfg.DataOutPath, strings.ToLower(pr.Name)+pr.cfg.DataOutExt))
+	}
+
+	f, err := os.Create(filepath.Join(pr.cfg.DataOutPath, strings.ToLower(pr.Name)+pr.cfg.DataOutExt))
 	if err != nil {
-		panic(err)
+		return err
 	}
+	defer f.Close()
 
 	w := bufio.NewWriter(f)
 	_, err = w.Write(pr.buf.Bytes())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	err = w.Flush()
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	if err := f.Close(); err != nil {
-		fmt.Println(err.Error())
-	}
+	return f.Close()
 }
 
 // Hash generate hash of proto content
@@ -1,19 +1,16 @@
 package lib
 
 import (
+	"errors"
 	"os"
 	"testing"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 	"github.com/tealeg/xlsx"
 )
 
 var (
-	testSheet *xlsx.Sheet
-	xlsxPath  string
-	protoPath string
-	dataPath  string
+	testSheet Sheet
 )
 
 func init() {
@@ -22,29 +19,33 @@ func init() {
 		panic(err)
 	}
 
-	if testSheet = xf.Sheet["SAMPLEONE"]; testSheet == nil {
+	sheet, ok := xf.Sheet["SAMPLEONE"]
+	if !ok {
 		panic("Unable to find test sheet!")
 	}
+	testSheet = &xlsxSheet{sheet: sheet}
 }
 
-func MockUp() {
-	xlsxPath = cfg.XlsxPath
-	cfg.XlsxPath = "../test/"
-	protoPath = cfg.ProtoOutPath
-	cfg.ProtoOutPath = "../test/"
-	dataPath = cfg.DataOutPath
-	cfg.DataOutPath = "../test/"
-}
+// newTestSheet build a sheet in memory
+func newTestSheet(name string, rows [][]string) Sheet {
+	sheet, err := xlsx.NewFile().AddSheet(name)
+	if err != nil {
+		panic(err)
+	}
 
-func TearDown() {
-	cfg.XlsxPath = xlsxPath
-	cfg.ProtoOutPath = protoPath
-	cfg.DataOutPath = dataPath
+	for _, cells := range rows {
+		row := sheet.AddRow()
+		for _, value := range cells {
+			row.AddCell().SetString(value)
+		}
+	}
+
+	return &xlsxSheet{sheet: sheet}
 }
 
 func TestReadHeads(t *testing.T) {
-	pr := newProtoRow()
-	pr.updateHeads(testSheet)
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(testSheet))
 
 	assert.Equal(t, 3, len(pr.vars))
 	assert.Equal(t, "uint64", pr.vars[0].typ)
@@ -63,14 +64,14 @@ func TestReadHeads(t *testing.T) {
 /*func TestWriteProto(t *testing.T) {
 	tarFile := "../test/sampleone.proto"
 
-	pr := newProtoRow()
+	pr := newProtoRow(newTestConfig())
 	pr.updateHeads(testSheet)
 	pr.GenProto()
 	assert.Equal(t, len(pr.outProto), 27)
 	pr.WriteProto()
 	assert.Equal(t, getFileMD5("../test/sampleone2.proto"), getFileMD5(tarFile))
 
-	pr = newProtoRow()
+	pr = newProtoRow(newTestConfig())
 	pr.updateHeads(testSheet)
 	pr.isProto3 = true
 	pr.GenProto()
@@ -84,49 +85,73 @@ func TestReadCell(t *testing.T) {
 	tests := []struct {
 		cellValue string
 		readType  string
-		expected  []byte
+		expected  interface{}
 		err       error
 	}{
-		{"3", "int32", []byte{0, 3}, nil},
-		{"150", "uint32", []byte{0, 150, 1}, nil},
-		{"270", "int64", []byte{0, 142, 2}, nil},
-		{"86942", "uint64", []byte{0, 158, 167, 5}, nil},
-		{"128", "sint32", []byte{0, 128, 2}, nil},
-		{"-2", "sint64", []byte{0, 3}, nil},
-		{"-0.85", "float", []byte{5, 0x9a, 0x99, 0x59, 0xbf}, nil},
-		{"0.8", "double", []byte{1, 0x9a, 0x99, 0x99, 0x99, 0x99, 0x99, 0xe9, 0x3f}, nil},
-		{"testing", "string", []byte{2, 7, 116, 101, 115, 116, 105, 110, 103}, nil},
-		{" testing \t", "string", []byte{2, 7, 116, 101, 115, 116, 105, 110, 103}, nil},
-		{"2147483648", "int32", []byte{0}, ErrFormatInvalid},
-		{"-1", "uint32", []byte{0}, ErrFormatInvalid},
-		{"-9223372036854775809", "int64", []byte{0}, ErrFormatInvalid},
-		{"-5", "uint64", []byte{0}, ErrFormatInvalid},
+		{"3", "int32", int32(3), nil},
+		{"150", "uint32", uint32(150), nil},
+		{"270", "int64", int64(270), nil},
+		{"86942", "uint64", uint64(86942), nil},
+		{"128", "sint32", int32(128), nil},
+		{"-2", "sint64", int64(-2), nil},
+		{"-0.85", "float", float32(-0.85), nil},
+		{"0.8", "double", 0.8, nil},
+		{"testing", "string", "testing", nil},
+		{" testing \t", "string", "testing", nil},
+		{"TRUE", "bool", true, nil},
+		{"0", "bool", false, nil},
+		{"Yes", "bool", true, nil},
+		{"no", "bool", false, nil},
+		{"", "int32", nil, nil},
+		{"maybe", "bool", nil, ErrFormatInvalid},
+		{"2147483648", "int32", nil, ErrFormatInvalid},
+		{"-1", "uint32", nil, ErrFormatInvalid},
+		{"-9223372036854775809", "int64", nil, ErrFormatInvalid},
+		{"-5", "uint64", nil, ErrFormatInvalid},
 	}
 
-	cell := new(xlsx.Cell)
-	buff := proto.NewBuffer([]byte{})
 	val := new(Val)
 
 	for i, test := range tests {
-		buff.Reset()
 		val.typ = test.readType
-		cell.SetString(test.cellValue)
-		err := readCell(buff, val, cell)
-		assert.Equal(t, test.expected, buff.Bytes(), i)
+		v, ok, err := readCell(val, test.cellValue)
 		assert.Equal(t, test.err, err, i)
+		assert.Equal(t, test.expected != nil, ok, i)
+		if ok {
+			assert.Equal(t, test.expected, v.Interface(), i)
+		}
 	}
+
+	val.typ = "int32"
+	val.proto2Type = Req
+	_, _, err := readCell(val, " ")
+	assert.Equal(t, ErrRequiredFieldEmpty, err)
 }
 
 func TestReadData(t *testing.T) {
-	pr := newProtoRow()
-	pr.updateHeads(testSheet)
-	pr.readData(testSheet)
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(testSheet))
+	assert.NoError(t, pr.readData(testSheet))
 
 	assert.Equal(t, 260, len(pr.buf.Bytes()))
 }
 
+func TestReadRowError(t *testing.T) {
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(testSheet))
+
+	row := []string{"-1", "1", "1"} // SampleID is uint64
+
+	_, err := pr.readRow(row)
+	var se *SheetError
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, 1, se.Col)
+	assert.Equal(t, "SampleID", se.Field)
+	assert.Equal(t, ErrFormatInvalid, se.Err)
+}
+
 func TestReadSheet(t *testing.T) {
-	MockUp()
+	cv := newConverter(newTestConfig())
 
 	rmTmp := func() {
 		for _, tmp := range []string{"../test/sampleone.data", "../test/sampleone.proto"} {
@@ -136,10 +161,8 @@ func TestReadSheet(t *testing.T) {
 		}
 	}
 
-	err := ReadSheet("Sample.xlsx", "SAMPLEONE")
+	err := cv.ReadSheet("Sample.xlsx", "SAMPLEONE")
 	assert.Nil(t, err)
 
 	rmTmp()
-
-	TearDown()
 }
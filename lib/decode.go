@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Decode print data file of a sheet as text, using descriptor generated with proto file
+// the text is the same as text dump
+// target must be set if targets are declared in config, since data files are only written for targets
+func (cv *Converter) Decode(sheetName, target string, w io.Writer) error {
+	cfg := cv.cfg
+	switch {
+	case target != "" && !contains(cfg.Targets, target):
+		return fmt.Errorf("%w %s, targets in config are %v", ErrUnknownTarget, target, cfg.Targets)
+	case target != "":
+		cfg = cfg.forTarget(target)
+	case len(cfg.Targets) > 0:
+		return fmt.Errorf("%w, choose one of %v", ErrUnknownTarget, cfg.Targets)
+	}
+
+	array, err := loadArray(cfg, sheetName)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(marshalText(array))
+
+	return err
+}
+
+// loadArray read XXX_ARRAY message of a sheet from written descriptor and data files
+func loadArray(cfg *Config, sheetName string) (protoreflect.Message, error) {
+	fn := strings.ToLower(sheetName)
+
+	fd, err := readDescriptor(filepath.Join(cfg.ProtoOutPath, fn+DescriptorExt))
+	if err != nil {
+		return nil, err
+	}
+	file, err := protodesc.NewFile(fd, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var arrayDesc protoreflect.MessageDescriptor
+	for i := 0; i < file.Messages().Len(); i++ {
+		if msg := file.Messages().Get(i); strings.HasSuffix(string(msg.Name()), "_ARRAY") {
+			arrayDesc = msg
+		}
+	}
+	if arrayDesc == nil {
+		return nil, fmt.Errorf("message %s_ARRAY not found in %s", sheetName, fd.GetName())
+	}
+
+	rawData, err := ioutil.ReadFile(filepath.Join(cfg.DataOutPath, fn+cfg.DataOutExt))
+	if err != nil {
+		return nil, err
+	}
+
+	array := dynamicpb.NewMessage(arrayDesc)
+	if err := (protov2.UnmarshalOptions{AllowPartial: true}).Unmarshal(rawData, array); err != nil {
+		return nil, err
+	}
+
+	return array, nil
+}
@@ -0,0 +1,50 @@
+package lib
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeAndClean(t *testing.T) {
+	cfg := newTempConfig(t)
+	cfg.TextDump = true
+	cv := newConverter(cfg)
+
+	assert.NoError(t, cv.ReadSheet("Sample.xlsx", "SAMPLEONE"))
+
+	var buf bytes.Buffer
+	assert.NoError(t, cv.Decode("SAMPLEONE", "", &buf))
+	assert.Contains(t, buf.String(), "items:")
+	assert.Contains(t, buf.String(), "RewardID:")
+	text, err := ioutil.ReadFile(filepath.Join(cfg.DataOutPath, "sampleone"+TextExt))
+	assert.NoError(t, err)
+	assert.Equal(t, string(text), buf.String())
+
+	assert.Error(t, cv.Decode("SAMPLETWO", "", &buf))
+
+	assert.NoError(t, cv.Clean())
+	for _, file := range []string{
+		filepath.Join(cfg.ProtoOutPath, "sampleone.proto"),
+		filepath.Join(cfg.ProtoOutPath, "sampleone.pb"),
+		filepath.Join(cfg.DataOutPath, "sampleone.data"),
+	} {
+		_, err := os.Stat(file)
+		assert.True(t, os.IsNotExist(err), file)
+	}
+}
+
+func TestCleanEmptyExt(t *testing.T) {
+	cfg := newTempConfig(t)
+	cfg.JSONOutPath = t.TempDir()
+	other := filepath.Join(cfg.JSONOutPath, "readme.txt")
+	assert.NoError(t, ioutil.WriteFile(other, []byte("not generated"), 0644))
+
+	assert.NoError(t, newConverter(cfg).Clean())
+	_, err := os.Stat(other)
+	assert.NoError(t, err)
+}
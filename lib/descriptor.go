@@ -0,0 +1,353 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// DescriptorExt is the extension of binary FileDescriptorSet output next to proto file
+const DescriptorExt = ".pb"
+
+// Field numbers in descriptor.proto, used as source code info path
+const (
+	fileMessageTypeTag = 4 // FileDescriptorProto.message_type
+	msgFieldTag        = 2 // DescriptorProto.field
+	msgNestedTypeTag   = 3 // DescriptorProto.nested_type
+	msgEnumTypeTag     = 4 // DescriptorProto.enum_type
+	enumValueTag       = 2 // EnumDescriptorProto.value
+)
+
+// marshalOptions keeps data output stable, required fields are checked by readCell
+var marshalOptions = protov2.MarshalOptions{Deterministic: true, AllowPartial: true}
+
+// scalarTypes maps type in sheet to proto type
+var scalarTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"int32":   descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	"int64":   descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"uint32":  descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	"uint64":  descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	"sint32":  descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+	"sint64":  descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+	"float":   descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	"float32": descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	"double":  descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	"float64": descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	"string":  descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	"bool":    descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+}
+
+// BuildDescriptor build file descriptor of current sheet
+// The descriptor is the only source of proto file, descriptor set and binary data
+func (pr *ProtoSheet) BuildDescriptor() error {
+	pr.applyFieldLock()
+
+	fd, err := pr.buildFileDescriptor()
+	if err != nil {
+		return err
+	}
+
+	file, err := protodesc.NewFile(fd, nil)
+	if err != nil {
+		return fmt.Errorf("invalid proto define of %s, %v", pr.Name, err)
+	}
+
+	pr.fileDesc = fd
+	pr.msgDesc = file.Messages().ByName(protoreflect.Name(pr.Name))
+	pr.arrayDesc = file.Messages().ByName(protoreflect.Name(pr.Name + "_ARRAY"))
+
+	return nil
+}
+
+func (pr *ProtoSheet) buildFileDescriptor() (*descriptorpb.FileDescriptorProto, error) {
+	syntax := "proto2"
+	if pr.isProto3 {
+		syntax = "proto3"
+	}
+
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:           protov2.String(strings.ToLower(pr.Name) + pr.cfg.ProtoOutExt),
+		Syntax:         protov2.String(syntax),
+		SourceCodeInfo: new(descriptorpb.SourceCodeInfo),
+	}
+	if pr.cfg.PackageName != "" {
+		fd.Package = protov2.String(pr.cfg.PackageName)
+	}
+	if pr.cfg.GoPackage != "" {
+		fd.Options = &descriptorpb.FileOptions{GoPackage: protov2.String(pr.cfg.GoPackage)}
+	}
+
+	msg := &descriptorpb.DescriptorProto{Name: protov2.String(pr.Name)}
+	msgPath := []int32{fileMessageTypeTag, 0}
+	if pr.lock != nil {
+		addReserved(msg, pr.lock)
+	}
+
+	// Enums
+	for _, e := range pr.usedEnums() {
+		path := appendPath(msgPath, msgEnumTypeTag, len(msg.EnumType))
+		msg.EnumType = append(msg.EnumType, enumDescriptor(fd, e, path))
+	}
+
+	addField := func(field *descriptorpb.FieldDescriptorProto, comment string) {
+		addComment(fd, appendPath(msgPath, msgFieldTag, len(msg.Field)), comment)
+		msg.Field = append(msg.Field, field)
+	}
+
+	// Vars
+	for _, val := range pr.vars {
+		field, err := pr.fieldDescriptor(val, val.name, val.fieldNum, false)
+		if err != nil {
+			return nil, err
+		}
+		addField(field, val.comment)
+	}
+
+	// Optional Struct
+	for _, optS := range pr.optStructs {
+		if err := pr.addNestedType(fd, msg, msgPath, optS.name, optS.fields); err != nil {
+			return nil, err
+		}
+		addField(&descriptorpb.FieldDescriptorProto{
+			Name:     protov2.String(optS.fieldName()),
+			Number:   protov2.Int32(int32(optS.fieldNum)),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			TypeName: protov2.String(pr.fullName(pr.Name, optS.name)),
+		}, "")
+	}
+
+	// Repeats
+	for _, repeat := range pr.repeats {
+		if repeat.key != nil {
+			if err := pr.addMapEntry(msg, repeat); err != nil {
+				return nil, err
+			}
+			addField(&descriptorpb.FieldDescriptorProto{
+				Name:     protov2.String(repeat.name),
+				Number:   protov2.Int32(int32(repeat.fieldNum)),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: protov2.String(pr.fullName(pr.Name, mapEntryName(repeat.name))),
+			}, repeat.comment)
+			continue
+		}
+
+		if repeat.val != nil {
+			field, err := pr.fieldDescriptor(repeat.val, repeat.val.name, repeat.fieldNum, true)
+			if err != nil {
+				return nil, err
+			}
+			addField(field, repeat.val.comment)
+			continue
+		}
+
+		if err := pr.addNestedType(fd, msg, msgPath, repeat.opts.name, repeat.opts.fields); err != nil {
+			return nil, err
+		}
+		comment := ""
+		if repeat.inline {
+			comment = repeat.comment
+		}
+		addField(&descriptorpb.FieldDescriptorProto{
+			Name:     protov2.String(repeat.fieldName()),
+			Number:   protov2.Int32(int32(repeat.fieldNum)),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			TypeName: protov2.String(pr.fullName(pr.Name, repeat.opts.name)),
+		}, comment)
+	}
+
+	// MessageArray
+	array := &descriptorpb.DescriptorProto{
+		Name: protov2.String(pr.Name + "_ARRAY"),
+		Field: []*descriptorpb.FieldDescriptorProto{{
+			Name:     protov2.String("items"),
+			Number:   protov2.Int32(1),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			TypeName: protov2.String(pr.fullName(pr.Name)),
+		}},
+	}
+
+	fd.MessageType = []*descriptorpb.DescriptorProto{msg, array}
+
+	return fd, nil
+}
+
+// addNestedType add a nested message for optional struct or repeated struct, structs with the same name are added once
+func (pr *ProtoSheet) addNestedType(fd *descriptorpb.FileDescriptorProto, msg *descriptorpb.DescriptorProto, msgPath []int32, name string, vals []*Val) error {
+	for _, nested := range msg.NestedType {
+		if nested.GetName() == name {
+			return nil
+		}
+	}
+
+	nestedPath := appendPath(msgPath, msgNestedTypeTag, len(msg.NestedType))
+	nested := &descriptorpb.DescriptorProto{Name: protov2.String(name)}
+	if pr.lock != nil {
+		addReserved(nested, pr.lock.nested(name))
+	}
+	for _, val := range vals {
+		field, err := pr.fieldDescriptor(val, val.name, val.fieldNum, false)
+		if err != nil {
+			return err
+		}
+		addComment(fd, appendPath(nestedPath, msgFieldTag, len(nested.Field)), val.comment)
+		nested.Field = append(nested.Field, field)
+	}
+	msg.NestedType = append(msg.NestedType, nested)
+
+	return nil
+}
+
+// fieldDescriptor convert a val to field descriptor
+func (pr *ProtoSheet) fieldDescriptor(val *Val, name string, fieldNum int, isRepeat bool) (*descriptorpb.FieldDescriptorProto, error) {
+	field := &descriptorpb.FieldDescriptorProto{
+		Name:   protov2.String(name),
+		Number: protov2.Int32(int32(fieldNum)),
+	}
+
+	if val.enum != nil {
+		field.Type = descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum()
+		field.TypeName = protov2.String(pr.fullName(pr.Name, val.enum.Name))
+	} else if typ, ok := scalarTypes[val.typ]; ok {
+		field.Type = typ.Enum()
+	} else {
+		return nil, &SheetError{Col: val.colIdx + 1, Field: val.name, Err: fmt.Errorf("invalid var type: %v", val.typ)}
+	}
+
+	switch {
+	case isRepeat:
+		field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	case !pr.isProto3 && val.proto2Type == Req:
+		field.Label = descriptorpb.FieldDescriptorProto_LABEL_REQUIRED.Enum()
+	default:
+		field.Label = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	}
+
+	// default value is only for proto2
+	if !pr.isProto3 && !isRepeat && val.defaultValueStr != "" {
+		defaultValue := strings.TrimSpace(val.defaultValueStr)
+		if val.typ == "string" {
+			if unquoted, err := strconv.Unquote(defaultValue); err == nil {
+				defaultValue = unquoted
+			}
+		}
+		field.DefaultValue = protov2.String(defaultValue)
+	}
+
+	return field, nil
+}
+
+// addReserved add numbers and names of removed fields, consecutive numbers are merged into one range
+func addReserved(msg *descriptorpb.DescriptorProto, lock *MessageLock) {
+	for _, num := range lock.ReservedNums {
+		if n := len(msg.ReservedRange); n > 0 && msg.ReservedRange[n-1].GetEnd() == num {
+			msg.ReservedRange[n-1].End = protov2.Int32(num + 1)
+			continue
+		}
+		msg.ReservedRange = append(msg.ReservedRange, &descriptorpb.DescriptorProto_ReservedRange{
+			Start: protov2.Int32(num),
+			End:   protov2.Int32(num + 1), // exclusive
+		})
+	}
+	msg.ReservedName = append(msg.ReservedName, lock.ReservedNames...)
+}
+
+// enumDescriptor convert an enum to enum descriptor
+func enumDescriptor(fd *descriptorpb.FileDescriptorProto, e *Enum, path []int32) *descriptorpb.EnumDescriptorProto {
+	ed := &descriptorpb.EnumDescriptorProto{Name: protov2.String(e.Name)}
+	for i, v := range e.values {
+		addComment(fd, appendPath(path, enumValueTag, i), v.comment)
+		ed.Value = append(ed.Value, &descriptorpb.EnumValueDescriptorProto{
+			Name:   protov2.String(v.name),
+			Number: protov2.Int32(v.value),
+		})
+	}
+
+	return ed
+}
+
+// fullName returns full qualified name of a type, e.g. ".ProtobufGen.SAMPLEONE.StructName"
+func (pr *ProtoSheet) fullName(names ...string) string {
+	if pr.cfg.PackageName != "" {
+		names = append([]string{pr.cfg.PackageName}, names...)
+	}
+
+	return "." + strings.Join(names, ".")
+}
+
+// appendPath returns a new source code path
+func appendPath(path []int32, tag, idx int) []int32 {
+	newPath := make([]int32, len(path), len(path)+2)
+	copy(newPath, path)
+
+	return append(newPath, int32(tag), int32(idx))
+}
+
+// addComment save comment as leading comment of source code info
+func addComment(fd *descriptorpb.FileDescriptorProto, path []int32, comment string) {
+	if comment == "" {
+		return
+	}
+
+	fd.SourceCodeInfo.Location = append(fd.SourceCodeInfo.Location, &descriptorpb.SourceCodeInfo_Location{
+		Path:            path,
+		Span:            []int32{0, 0, 0},
+		LeadingComments: protov2.String(comment),
+	})
+}
+
+// readDescriptor read file descriptor from a descriptor set written by WriteDescriptor
+func readDescriptor(descFile string) (*descriptorpb.FileDescriptorProto, error) {
+	rawData, err := ioutil.ReadFile(descFile)
+	if err != nil {
+		return nil, err
+	}
+
+	fds := new(descriptorpb.FileDescriptorSet)
+	if err := protov2.Unmarshal(rawData, fds); err != nil {
+		return nil, err
+	}
+	if len(fds.File) != 1 {
+		return nil, fmt.Errorf("%s should contain 1 file, got %d", descFile, len(fds.File))
+	}
+
+	return fds.File[0], nil
+}
+
+// WriteDescriptor output binary FileDescriptorSet to "./proto/sheetname.pb"
+func (pr *ProtoSheet) WriteDescriptor() error {
+	raw, err := marshalOptions.Marshal(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{pr.fileDesc},
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(pr.cfg.ProtoOutPath, strings.ToLower(pr.Name)+DescriptorExt))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
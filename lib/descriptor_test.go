@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestBuildDescriptor(t *testing.T) {
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(testSheet))
+	assert.NoError(t, pr.BuildDescriptor())
+
+	assert.Equal(t, "ProtobufGen.SAMPLEONE", string(pr.msgDesc.FullName()))
+	assert.Equal(t, "ProtobufGen.SAMPLEONE.StructName", string(pr.msgDesc.Fields().ByName("structnames").Message().FullName()))
+	assert.Equal(t, protoreflect.Required, pr.msgDesc.Fields().ByNumber(1).Cardinality())
+	assert.Equal(t, "ProtobufGen.SAMPLEONE", string(pr.arrayDesc.Fields().ByName("items").Message().FullName()))
+}
+
+func TestWriteDescriptor(t *testing.T) {
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(testSheet))
+	assert.NoError(t, pr.readData(testSheet))
+	assert.NoError(t, pr.WriteDescriptor())
+
+	tarFile := "../test/sampleone" + DescriptorExt
+	raw, err := ioutil.ReadFile(tarFile)
+	assert.NoError(t, err)
+	os.Remove(tarFile)
+
+	// data can be decoded with descriptor set only
+	fds := new(descriptorpb.FileDescriptorSet)
+	assert.NoError(t, protov2.Unmarshal(raw, fds))
+	files, err := protodesc.NewFiles(fds)
+	assert.NoError(t, err)
+	desc, err := files.FindDescriptorByName("ProtobufGen.SAMPLEONE_ARRAY")
+	assert.NoError(t, err)
+
+	array := dynamicpb.NewMessage(desc.(protoreflect.MessageDescriptor))
+	assert.NoError(t, protov2.Unmarshal(pr.buf.Bytes(), array))
+	items := array.Get(array.Descriptor().Fields().ByName("items")).List()
+	assert.True(t, items.Len() > 0)
+
+	data, err := marshalOptions.Marshal(array)
+	assert.NoError(t, err)
+	assert.Equal(t, pr.buf.Bytes(), data)
+}
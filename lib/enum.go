@@ -0,0 +1,241 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Enum sheets are named as ENUM_<EnumName>, and referred by column type enum:<EnumName>
+const (
+	EnumSheetPrefix = "ENUM_"
+	EnumTypePrefix  = "enum:"
+)
+
+// Column index in enum sheet, the first row is title and will be ignored
+const (
+	EnumColName = iota
+	EnumColValue
+	EnumColComment
+)
+
+var (
+	ErrUnknownEnum = errors.New("unknown enum")
+	ErrEnumInvalid = errors.New("invalid enum sheet")
+)
+
+// Enum is declared in a dedicated sheet
+type Enum struct {
+	Name     string
+	values   []*EnumValue
+	valueMap map[string]int32 // map[valueName]value
+	numMap   map[int32]string // map[value]valueName
+}
+
+// EnumValue is one row in enum sheet
+type EnumValue struct {
+	name    string
+	comment string
+	value   int32
+}
+
+func newEnum(name string) *Enum {
+	e := new(Enum)
+	e.Name = name
+	e.valueMap = make(map[string]int32)
+	e.numMap = make(map[int32]string)
+
+	return e
+}
+
+// isEnumSheet check if a sheet declares an enum
+func isEnumSheet(sheetName string) bool {
+	return strings.HasPrefix(sheetName, EnumSheetPrefix)
+}
+
+// readEnumSheet read enum values from a sheet
+func readEnumSheet(sheet Sheet, isProto3 bool) (*Enum, error) {
+	e := newEnum(strings.TrimPrefix(strings.TrimSpace(sheet.Name()), EnumSheetPrefix))
+	if e.Name == "" {
+		return nil, fmt.Errorf("%w, sheet %s has no enum name", ErrEnumInvalid, sheet.Name())
+	}
+
+	for i := 1; i < sheet.MaxRow(); i++ {
+		name := strings.TrimSpace(sheet.Cell(i, EnumColName))
+		if name == "" {
+			continue
+		}
+
+		num, err := parseInt(sheet.Cell(i, EnumColValue))
+		if err != nil || int(int32(num)) != num {
+			return nil, &SheetError{Row: i + 1, Col: EnumColValue + 1, Field: name, Err: ErrFormatInvalid}
+		}
+
+		if err := e.addValue(name, int32(num), strings.TrimSpace(sheet.Cell(i, EnumColComment))); err != nil {
+			return nil, &SheetError{Row: i + 1, Col: EnumColName + 1, Field: name, Err: err}
+		}
+	}
+
+	if len(e.values) == 0 {
+		return nil, fmt.Errorf("%w, enum %s has no value", ErrEnumInvalid, e.Name)
+	}
+	if isProto3 && e.values[0].value != 0 {
+		return nil, fmt.Errorf("%w, first value of enum %s must be 0 in proto3", ErrEnumInvalid, e.Name)
+	}
+
+	return e, nil
+}
+
+func (e *Enum) addValue(name string, value int32, comment string) error {
+	if _, ok := e.valueMap[name]; ok {
+		return fmt.Errorf("%w, duplicate name %s", ErrEnumInvalid, name)
+	}
+	if _, ok := e.numMap[value]; ok {
+		return fmt.Errorf("%w, duplicate value %d", ErrEnumInvalid, value)
+	}
+
+	e.values = append(e.values, &EnumValue{name: name, value: value, comment: comment})
+	e.valueMap[name] = value
+	e.numMap[value] = name
+
+	return nil
+}
+
+// parse convert a value name or number to enum value
+func (e *Enum) parse(cellValue string) (int32, error) {
+	cellValue = strings.TrimSpace(cellValue)
+
+	if value, ok := e.valueMap[cellValue]; ok {
+		return value, nil
+	}
+
+	if num, err := strconv.ParseInt(cellValue, 10, 32); err == nil {
+		if _, ok := e.numMap[int32(num)]; ok {
+			return int32(num), nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w, %s is not a value of enum %s", ErrFormatInvalid, cellValue, e.Name)
+}
+
+// loadEnums read all enum sheets before handling other sheets
+// returns true if any xlsx containing enum sheets changed
+func (cv *Converter) loadEnums(report *Report) bool {
+	cv.enums = make(map[string]*Enum)
+	changed := false
+
+	for filename, sheets := range cv.enumFileMap {
+		fileChanged, err := cv.IsXlsxChanged(filename)
+		if err != nil {
+			report.addError(toSheetError(filename, "", err))
+			cv.ForgetXlsx(filename)
+			changed = true
+			continue
+		}
+		if fileChanged {
+			changed = true
+		}
+
+		source, err := openSource(cv.cfg.sourcePath(filename))
+		if err != nil {
+			report.addError(toSheetError(filename, "", err))
+			cv.ForgetXlsx(filename)
+			continue
+		}
+
+		for _, sheetName := range sheets {
+			sheet, ok := source.Sheet(sheetName)
+			if !ok {
+				report.addError(toSheetError(filename, sheetName, fmt.Errorf("xlsx file %s does not contain sheet %s", filename, sheetName)))
+				cv.ForgetXlsx(filename)
+				continue
+			}
+
+			e, err := readEnumSheet(sheet, cv.cfg.UseProto3)
+			if err != nil {
+				report.addError(toSheetError(filename, sheetName, err))
+				cv.ForgetXlsx(filename)
+				continue
+			}
+			if _, ok := cv.enums[e.Name]; ok {
+				report.addError(toSheetError(filename, sheetName, fmt.Errorf("%w, enum %s declared more than once", ErrEnumInvalid, e.Name)))
+				continue
+			}
+
+			cv.enums[e.Name] = e
+		}
+	}
+
+	return changed
+}
+
+// resolveEnum link val to the enum declared in its type, e.g. "enum:ItemQuality"
+func (pr *ProtoSheet) resolveEnum(val *Val) error {
+	if !strings.HasPrefix(val.typ, EnumTypePrefix) {
+		return nil
+	}
+
+	name := strings.TrimSpace(strings.TrimPrefix(val.typ, EnumTypePrefix))
+	e, ok := pr.enums[name]
+	if !ok {
+		return &SheetError{Col: val.colIdx + 1, Field: val.name, Err: fmt.Errorf("%w %s", ErrUnknownEnum, name)}
+	}
+
+	val.typ = e.Name
+	val.enum = e
+
+	return nil
+}
+
+// checkEnumValues reject value names declared by more than one enum used by the sheet
+// enums are nested in the sheet message, so their values share the message scope
+func (pr *ProtoSheet) checkEnumValues() error {
+	owners := make(map[string]string)
+	for _, e := range pr.usedEnums() {
+		for _, v := range e.values {
+			if owner, ok := owners[v.name]; ok {
+				return fmt.Errorf("%w, value %s declared in both %s and %s", ErrEnumInvalid, v.name, owner, e.Name)
+			}
+			owners[v.name] = e.Name
+		}
+	}
+
+	return nil
+}
+
+// usedEnums returns enums used by the sheet in column order
+func (pr *ProtoSheet) usedEnums() []*Enum {
+	var enums []*Enum
+	used := make(map[string]struct{})
+
+	add := func(val *Val) {
+		if val == nil || val.enum == nil {
+			return
+		}
+		if _, ok := used[val.enum.Name]; ok {
+			return
+		}
+		used[val.enum.Name] = struct{}{}
+		enums = append(enums, val.enum)
+	}
+
+	for _, val := range pr.vars {
+		add(val)
+	}
+	for _, optS := range pr.optStructs {
+		for _, val := range optS.fields {
+			add(val)
+		}
+	}
+	for _, repeat := range pr.repeats {
+		add(repeat.val)
+		if repeat.opts != nil {
+			for _, val := range repeat.opts.fields {
+				add(val)
+			}
+		}
+	}
+
+	return enums
+}
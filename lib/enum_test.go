@@ -0,0 +1,141 @@
+package lib
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func newTestEnum() *Enum {
+	e, err := readEnumSheet(newTestSheet("ENUM_ItemQuality", [][]string{
+		{"name", "value", "comment"},
+		{"Common", "1", "white"},
+		{"", "", ""},
+		{"Rare", "2", ""},
+		{"Legendary", "5", "orange"},
+	}), false)
+	if err != nil {
+		panic(err)
+	}
+
+	return e
+}
+
+func TestReadEnumSheet(t *testing.T) {
+	e := newTestEnum()
+	assert.Equal(t, "ItemQuality", e.Name)
+	assert.Equal(t, 3, len(e.values))
+	assert.Equal(t, "white", e.values[0].comment)
+
+	tests := []struct {
+		rows     [][]string
+		isProto3 bool
+	}{
+		{[][]string{{"name", "value"}}, false},                           // no value
+		{[][]string{{"name", "value"}, {"A", "1"}, {"A", "2"}}, false},   // duplicate name
+		{[][]string{{"name", "value"}, {"A", "1"}, {"B", "1"}}, false},   // duplicate value
+		{[][]string{{"name", "value"}, {"A", "x"}}, false},               // invalid value
+		{[][]string{{"name", "value"}, {"A", "2147483648"}}, false},      // overflow
+		{[][]string{{"name", "value"}, {"A", "1"}, {"Zero", "0"}}, true}, // proto3 starts with 0
+	}
+
+	for i, test := range tests {
+		_, err := readEnumSheet(newTestSheet("ENUM_Test", test.rows), test.isProto3)
+		assert.Error(t, err, i)
+	}
+}
+
+func TestEnumParse(t *testing.T) {
+	e := newTestEnum()
+
+	v, err := e.parse(" Legendary ")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(5), v)
+
+	v, err = e.parse("2")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), v)
+
+	_, err = e.parse("3")
+	assert.True(t, errors.Is(err, ErrFormatInvalid))
+	_, err = e.parse("Epic")
+	assert.True(t, errors.Is(err, ErrFormatInvalid))
+}
+
+func TestEnumColumn(t *testing.T) {
+	sheet := newTestSheet("ITEM", [][]string{
+		{"required", "optional"},
+		{"uint32", "enum:ItemQuality"},
+		{"ItemID", "Quality"},
+		{"ID", "Quality of item"},
+		{"1001", "Legendary"},
+	})
+
+	pr := newProtoRow(newTestConfig())
+	err := pr.updateHeads(sheet)
+	assert.True(t, errors.Is(err, ErrUnknownEnum))
+
+	pr = newProtoRow(newTestConfig())
+	pr.enums = map[string]*Enum{"ItemQuality": newTestEnum()}
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.Equal(t, "ItemQuality", pr.vars[1].typ)
+
+	v, ok, err := readCell(pr.vars[1], "Legendary")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, protoreflect.EnumNumber(5), v.Enum())
+
+	assert.NoError(t, pr.readData(sheet))
+	assert.Equal(t, []byte{10, 5, 8, 233, 7, 16, 5}, pr.buf.Bytes())
+
+	assert.NoError(t, pr.GenProto())
+	assert.Contains(t, pr.outProto, "  enum ItemQuality {")
+	assert.Contains(t, pr.outProto, "    /* white */")
+	assert.Contains(t, pr.outProto, "    Legendary = 5;")
+	assert.Contains(t, pr.outProto, "  optional ItemQuality Quality = 2;")
+}
+
+func TestEnumValueClash(t *testing.T) {
+	newEnum := func(name string, rows ...[]string) *Enum {
+		e, err := readEnumSheet(newTestSheet(EnumSheetPrefix+name, append([][]string{{"name", "value"}}, rows...)), false)
+		if err != nil {
+			panic(err)
+		}
+		return e
+	}
+	sheet := newTestSheet("STAGE", [][]string{
+		{"required", "optional", "optional"},
+		{"uint32", "enum:Difficulty", "enum:Weather"},
+		{"StageID", "Level", "Sky"},
+		{"ID", "", ""},
+		{"1", "Hard", "Rain"},
+	})
+
+	pr := newProtoRow(newTestConfig())
+	pr.enums = map[string]*Enum{
+		"Difficulty": newEnum("Difficulty", []string{"None", "0"}, []string{"Hard", "1"}),
+		"Weather":    newEnum("Weather", []string{"None", "0"}, []string{"Rain", "1"}),
+	}
+	err := pr.updateHeads(sheet)
+	assert.True(t, errors.Is(err, ErrEnumInvalid))
+	assert.Contains(t, err.Error(), "None")
+
+	pr = newProtoRow(newTestConfig())
+	pr.enums = map[string]*Enum{
+		"Difficulty": newEnum("Difficulty", []string{"DifficultyNone", "0"}, []string{"Hard", "1"}),
+		"Weather":    newEnum("Weather", []string{"WeatherNone", "0"}, []string{"Rain", "1"}),
+	}
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.readData(sheet))
+	assert.NoError(t, pr.GenProto())
+}
+
+func TestReadCfgLineEnum(t *testing.T) {
+	cv := newConverter(newTestConfig())
+
+	assert.NoError(t, cv.readCfgLine("ENUM_ItemQuality,ENUM_Color Enums.xlsx"))
+	assert.Equal(t, []string{"ENUM_ItemQuality", "ENUM_Color"}, cv.enumFileMap["Enums.xlsx"])
+	assert.Equal(t, 0, len(cv.sheetFileMap))
+}
@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"strings"
+)
+
+// Rows can be disabled without clearing the first cell
+const (
+	DisabledRowPrefix = "#"        // a row is skipped if its first cell starts with it, e.g. "#1001"
+	ExportColumn      = "__export" // id of a column holds true/false or export targets of each row
+)
+
+// filterRow check if a row is disabled, and returns export targets of the row, nil for all targets
+func (pr *ProtoSheet) filterRow(row []string) (bool, []string, error) {
+	if len(row) > 0 && strings.HasPrefix(strings.TrimSpace(row[0]), DisabledRowPrefix) {
+		return true, nil, nil
+	}
+
+	if pr.exportCol < 0 || pr.exportCol >= len(row) {
+		return false, nil, nil
+	}
+	value := strings.TrimSpace(row[pr.exportCol])
+	if value == "" {
+		return false, nil, nil
+	}
+	if export, err := parseBool(value); err == nil {
+		return !export, nil, nil
+	}
+
+	_, targets := splitAttr(TargetSep + value)
+	if err := pr.checkTargets(pr.exportCol, targets); err != nil {
+		return false, nil, err
+	}
+
+	return false, targets, nil
+}
+
+// rowExports check if the ith row in data is exported to target
+func (pr *ProtoSheet) rowExports(i int, target string) bool {
+	return i >= len(pr.rowTargets) || len(pr.rowTargets[i]) == 0 || contains(pr.rowTargets[i], target)
+}
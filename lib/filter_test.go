@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterRows(t *testing.T) {
+	sheet := newTestSheet("FILTERTEST", [][]string{
+		{"unique", "optional", ""},
+		{"uint32", "string", ""},
+		{"ID", "Name", ExportColumn},
+		{"", "", ""},
+		{"1", "a", ""},
+		{"#2", "b", ""},
+		{"3", "c", "false"},
+		{"4", "d", "TRUE"},
+		{"5", "e", "client"},
+		{"6", "f", "server, client"},
+	})
+
+	cfg := newTestConfig()
+	cfg.Targets = []string{"client", "server"}
+	pr := newProtoRow(cfg)
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.readData(sheet))
+	assert.Equal(t, 2, pr.skipped)
+
+	items, err := pr.decodeItems()
+	assert.NoError(t, err)
+	assert.Len(t, items, 4)
+
+	tests := []struct {
+		target string
+		json   string
+	}{
+		{"client", `[{"ID":1,"Name":"a"},{"ID":4,"Name":"d"},{"ID":5,"Name":"e"},{"ID":6,"Name":"f"}]`},
+		{"server", `[{"ID":1,"Name":"a"},{"ID":4,"Name":"d"},{"ID":6,"Name":"f"}]`},
+	}
+	for _, test := range tests {
+		tp, err := pr.forTarget(test.target)
+		assert.NoError(t, err)
+		items, err := tp.decodeItems()
+		assert.NoError(t, err)
+		raw, err := json.Marshal(items)
+		assert.NoError(t, err)
+		assert.Equal(t, test.json, string(raw), test.target)
+	}
+}
+
+func TestFilterRowsUnknownTarget(t *testing.T) {
+	sheet := newTestSheet("FILTERTEST", [][]string{
+		{"unique", ""},
+		{"uint32", ""},
+		{"ID", ExportColumn},
+		{"", ""},
+		{"1", "editor"},
+	})
+
+	cfg := newTestConfig()
+	cfg.Targets = []string{"client", "server"}
+	pr := newProtoRow(cfg)
+	assert.NoError(t, pr.updateHeads(sheet))
+	err := pr.readData(sheet)
+	assert.True(t, errors.Is(err, ErrUnknownTarget))
+	var se *SheetError
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, 5, se.Row)
+}
+
+func TestSummarySkipped(t *testing.T) {
+	report := new(Report)
+	report.addSheet("ITEM", 2)
+	report.addSheet("SKILL", 0)
+	assert.Equal(t, "2 sheet(s) converted, 0 failed\n  ITEM: 2 row(s) skipped\n", report.Summary())
+}
@@ -0,0 +1,316 @@
+package lib
+
+import (
+	"crypto/md5"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// GoLoaderExt is the extension of generated go loader, next to "sheetname.pb.go" generated by protoc-gen-go
+const GoLoaderExt = ".loader.go"
+
+// loaderIndex is a map of items built by generated loaders, on a unique column or a composite key
+type loaderIndex struct {
+	name   string                         // name of unique column or composite key
+	fields []protoreflect.FieldDescriptor // fields in the key, more than one for composite key
+}
+
+// loaderIndexes returns indexes of unique columns and composite keys, in column order
+// columns which can not be used as map keys are skipped
+func (pr *ProtoSheet) loaderIndexes() []*loaderIndex {
+	fieldOf := func(val *Val) protoreflect.FieldDescriptor {
+		field := pr.msgDesc.Fields().ByName(protoreflect.Name(val.name))
+		if field == nil || field.IsList() || field.IsMap() || field.Kind() == protoreflect.MessageKind || field.Kind() == protoreflect.BytesKind {
+			return nil
+		}
+		return field
+	}
+
+	keys := make(map[string]*UniqueKey)
+	for _, key := range groupUniqueKeys(pr.vars) {
+		keys[key.name] = key
+	}
+
+	var indexes []*loaderIndex
+	for _, val := range pr.vars {
+		if val.colIdx < 0 {
+			continue
+		}
+
+		if val.proto2Type == Unique {
+			if field := fieldOf(val); field != nil {
+				indexes = append(indexes, &loaderIndex{name: val.name, fields: []protoreflect.FieldDescriptor{field}})
+			}
+			continue
+		}
+
+		key, ok := keys[val.uniqueKey]
+		if !ok || key.vals[0] != val {
+			continue
+		}
+		index := &loaderIndex{name: key.name}
+		for _, keyVal := range key.vals {
+			if field := fieldOf(keyVal); field != nil {
+				index.fields = append(index.fields, field)
+			}
+		}
+		if len(index.fields) == len(key.vals) {
+			indexes = append(indexes, index)
+		}
+	}
+
+	return indexes
+}
+
+// loaderHash returns hash of what loaders are generated from, indexes are not in proto
+// e.g. a column changed from optional to unique:key gets an index, but its field is the same
+func (pr *ProtoSheet) loaderHash() []byte {
+	hash := md5.New()
+	hash.Write(pr.protoHash)
+	fmt.Fprintf(hash, "package %s\n", pr.cfg.goPackageName())
+	fmt.Fprintf(hash, "namespace %s\n", pr.cfg.PackageName)
+	for _, index := range pr.loaderIndexes() {
+		fmt.Fprintf(hash, "index %s", index.name)
+		for _, field := range index.fields {
+			fmt.Fprintf(hash, " %s", field.Name())
+		}
+		fmt.Fprintln(hash)
+	}
+
+	return hash.Sum(nil)
+}
+
+// goPackageName returns package name of generated go files, the same package as protoc-gen-go outputs
+func (c *Config) goPackageName() string {
+	name := path.Base(filepath.ToSlash(c.GoOutPath))
+	if c.GoPackage != "" {
+		name = path.Base(c.GoPackage)
+		if idx := strings.LastIndex(c.GoPackage, ";"); idx >= 0 { // "example.com/config;config"
+			name = c.GoPackage[idx+1:]
+		}
+	}
+
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+
+	return name
+}
+
+// GenGo generate go loader of current sheet, which reads XXX_ARRAY from data file
+// if sheet has unique columns, the array is returned in a table which indexes items by them
+func (pr *ProtoSheet) GenGo() ([]byte, error) {
+	var sb strings.Builder
+
+	arrayType := pr.goName(pr.arrayDesc.FullName())
+	itemType := pr.goName(pr.msgDesc.FullName())
+	indexes := pr.loaderIndexes()
+
+	fmt.Fprintf(&sb, "// Code generated by xlsx2pb. DO NOT EDIT.\n// source: %s\n\n", pr.fileDesc.GetName())
+	fmt.Fprintf(&sb, "package %s\n\n", pr.cfg.goPackageName())
+	sb.WriteString("import (\n\"io/ioutil\"\n\n\"google.golang.org/protobuf/proto\"\n)\n\n")
+
+	resultType := arrayType
+	if len(indexes) > 0 {
+		resultType = itemType + "Table"
+	}
+	fmt.Fprintf(&sb, "// Load%s read %s from data file\n", pr.Name, arrayType)
+	fmt.Fprintf(&sb, "func Load%s(path string) (*%s, error) {\n", pr.Name, resultType)
+	sb.WriteString("raw, err := ioutil.ReadFile(path)\nif err != nil {\nreturn nil, err\n}\n\n")
+	fmt.Fprintf(&sb, "array := new(%s)\n", arrayType)
+	sb.WriteString("if err := (proto.UnmarshalOptions{AllowPartial: true}).Unmarshal(raw, array); err != nil {\nreturn nil, err\n}\n\n")
+	if len(indexes) == 0 {
+		sb.WriteString("return array, nil\n}\n")
+		return format.Source([]byte(sb.String()))
+	}
+	fmt.Fprintf(&sb, "return New%s(array), nil\n}\n", resultType)
+
+	// indexes are built once and kept with the array, items should not be changed after loaded
+	fmt.Fprintf(&sb, "\n// %s is %s with items indexed by unique columns\n", resultType, arrayType)
+	fmt.Fprintf(&sb, "type %s struct {\n*%s\n", resultType, arrayType)
+	for _, index := range indexes {
+		fmt.Fprintf(&sb, "by%s map[%s]*%s\n", goCamelCase(index.name), pr.goKeyType(index), itemType)
+	}
+	sb.WriteString("}\n")
+
+	for _, index := range indexes {
+		if len(index.fields) == 1 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n// %s is composite key %s\n", pr.goKeyType(index), index.name)
+		fmt.Fprintf(&sb, "type %s struct {\n", pr.goKeyType(index))
+		for _, field := range index.fields {
+			fmt.Fprintf(&sb, "%s %s\n", goCamelCase(string(field.Name())), pr.goType(field))
+		}
+		sb.WriteString("}\n")
+	}
+
+	fmt.Fprintf(&sb, "\n// New%s index items of array, items should not be changed after indexed\n", resultType)
+	fmt.Fprintf(&sb, "func New%s(array *%s) *%s {\n", resultType, arrayType, resultType)
+	sb.WriteString("items := array.GetItems()\n")
+	fmt.Fprintf(&sb, "table := &%s{%s: array}\n", resultType, arrayType)
+	for _, index := range indexes {
+		fmt.Fprintf(&sb, "table.by%s = make(map[%s]*%s, len(items))\n", goCamelCase(index.name), pr.goKeyType(index), itemType)
+	}
+	sb.WriteString("for _, item := range items {\n")
+	for _, index := range indexes {
+		fmt.Fprintf(&sb, "table.by%s[%s] = item\n", goCamelCase(index.name), pr.goKeyValue(index, func(field protoreflect.FieldDescriptor) string {
+			return "item.Get" + goCamelCase(string(field.Name())) + "()"
+		}))
+	}
+	sb.WriteString("}\n\nreturn table\n}\n")
+
+	for _, index := range indexes {
+		var names []string
+		params := make([]string, 0, len(index.fields))
+		for _, field := range index.fields {
+			names = append(names, string(field.Name()))
+			params = append(params, goParamName(string(field.Name()))+" "+pr.goType(field))
+		}
+		method := "GetBy" + goCamelCase(index.name)
+		fmt.Fprintf(&sb, "\n// %s returns the item with unique %s, or nil if not found\n", method, strings.Join(names, " and "))
+		fmt.Fprintf(&sb, "func (table *%s) %s(%s) *%s {\n", resultType, method, strings.Join(params, ", "), itemType)
+		fmt.Fprintf(&sb, "return table.by%s[%s]\n}\n", goCamelCase(index.name), pr.goKeyValue(index, func(field protoreflect.FieldDescriptor) string {
+			return goParamName(string(field.Name()))
+		}))
+	}
+
+	return format.Source([]byte(sb.String()))
+}
+
+// goKeyType returns type of map key, composite keys use a struct named by sheet and key
+func (pr *ProtoSheet) goKeyType(index *loaderIndex) string {
+	if len(index.fields) == 1 {
+		return pr.goType(index.fields[0])
+	}
+
+	return strings.ToLower(pr.Name) + goCamelCase(index.name) + "Key"
+}
+
+// goKeyValue returns expression of map key, values of fields are returned by value
+func (pr *ProtoSheet) goKeyValue(index *loaderIndex, value func(protoreflect.FieldDescriptor) string) string {
+	if len(index.fields) == 1 {
+		return value(index.fields[0])
+	}
+
+	values := make([]string, 0, len(index.fields))
+	for _, field := range index.fields {
+		values = append(values, value(field))
+	}
+
+	return fmt.Sprintf("%s{%s}", pr.goKeyType(index), strings.Join(values, ", "))
+}
+
+// goType returns go type of a scalar or enum field
+func (pr *ProtoSheet) goType(field protoreflect.FieldDescriptor) string {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return "bool"
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return "int32"
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return "uint32"
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return "int64"
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return "uint64"
+	case protoreflect.FloatKind:
+		return "float32"
+	case protoreflect.DoubleKind:
+		return "float64"
+	case protoreflect.EnumKind:
+		return pr.goName(field.Enum().FullName())
+	}
+
+	return "string"
+}
+
+// goName returns go type name of a message or enum generated by protoc-gen-go
+func (pr *ProtoSheet) goName(fullName protoreflect.FullName) string {
+	name := string(fullName)
+	if pkg := pr.fileDesc.GetPackage(); pkg != "" {
+		name = strings.TrimPrefix(name, pkg+".")
+	}
+
+	return goCamelCase(name)
+}
+
+// goParamName returns name of parameter for a field, e.g. "itemID" for "ItemID"
+func goParamName(name string) string {
+	name = lowerCamelCase(name)
+	if token.IsKeyword(name) || name == "table" {
+		name += "_"
+	}
+
+	return name
+}
+
+// lowerCamelCase lower the first word of a camel case name, e.g. "idName" for "IDName"
+func lowerCamelCase(name string) string {
+	name = goCamelCase(name)
+	upper := 0
+	for upper < len(name) && name[upper] >= 'A' && name[upper] <= 'Z' {
+		upper++
+	}
+	if upper > 1 && upper < len(name) { // keep the last upper letter of "IDName" as start of next word
+		upper--
+	}
+
+	return strings.ToLower(name[:upper]) + name[upper:]
+}
+
+// goCamelCase convert a proto name to go name, the same as protoc-gen-go
+func goCamelCase(s string) string {
+	isLower := func(c byte) bool { return c >= 'a' && c <= 'z' }
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && isLower(s[i+1]):
+			// skip "." in ".{{lowercase}}"
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			b = append(b, 'X') // names start with a capital letter
+		case c == '_' && i+1 < len(s) && isLower(s[i+1]):
+			// skip "_" in "_{{lowercase}}"
+		case isDigit(c):
+			b = append(b, c)
+		default:
+			if isLower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(s) && isLower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+
+	return string(b)
+}
+
+// WriteGo output go loader to "go_path/sheetname.loader.go"
+func (pr *ProtoSheet) WriteGo() error {
+	src, err := pr.GenGo()
+	if err != nil {
+		return fmt.Errorf("generate go loader of %s fail, %v", pr.Name, err)
+	}
+
+	return ioutil.WriteFile(filepath.Join(pr.cfg.GoOutPath, strings.ToLower(pr.Name)+GoLoaderExt), src, 0644)
+}
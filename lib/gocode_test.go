@@ -0,0 +1,152 @@
+package lib
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoCamelCase(t *testing.T) {
+	assert.Equal(t, "SAMPLEONE_ARRAY", goCamelCase("SAMPLEONE_ARRAY"))
+	assert.Equal(t, "ItemQuality", goCamelCase("item_quality"))
+	assert.Equal(t, "ITEM_ItemQuality", goCamelCase("ITEM.ItemQuality"))
+	assert.Equal(t, "XId", goCamelCase("_id"))
+
+	assert.Equal(t, "id", goParamName("ID"))
+	assert.Equal(t, "itemID", goParamName("ItemID"))
+	assert.Equal(t, "idName", goParamName("IDName"))
+	assert.Equal(t, "type_", goParamName("Type"))
+	assert.Equal(t, "table_", goParamName("Table"))
+}
+
+func TestGoPackageName(t *testing.T) {
+	cfg := &Config{GoOutPath: "/server/game-config"}
+	assert.Equal(t, "game_config", cfg.goPackageName())
+
+	cfg.GoOutPath = "/server/2d"
+	assert.Equal(t, "_2d", cfg.goPackageName())
+
+	cfg.GoPackage = "example.com/server/config"
+	assert.Equal(t, "config", cfg.goPackageName())
+
+	cfg.GoPackage = "example.com/server/config;tables"
+	assert.Equal(t, "tables", cfg.goPackageName())
+}
+
+func TestGenGo(t *testing.T) {
+	sheet := newTestSheet("STAGE", [][]string{
+		{"unique", "unique:key1", "unique:key1", "optional", "optional"},
+		{"uint32", "uint32", "uint32", "enum:ItemQuality", "string"},
+		{"ID", "ChapterID", "StageID", "Quality", "Name"},
+		{"", "", "", "", ""},
+		{"1", "1", "1", "Common", "a"},
+	})
+
+	cfg := newTestConfig()
+	cfg.GoPackage = "example.com/server/config"
+	pr := newProtoRow(cfg)
+	pr.enums = map[string]*Enum{"ItemQuality": newTestEnum()}
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.GenProto())
+	assert.Contains(t, pr.outProto, `option go_package = "example.com/server/config";`)
+
+	src, err := pr.GenGo()
+	assert.NoError(t, err)
+	code := string(src)
+	for _, line := range []string{
+		"package config",
+		"func LoadSTAGE(path string) (*STAGETable, error) {",
+		"\treturn NewSTAGETable(array), nil",
+		"type STAGETable struct {\n\t*STAGE_ARRAY\n",
+		"\tbyID   map[uint32]*STAGE",
+		"\tbyKey1 map[stageKey1Key]*STAGE",
+		"type stageKey1Key struct {",
+		"func NewSTAGETable(array *STAGE_ARRAY) *STAGETable {",
+		"\t\ttable.byKey1[stageKey1Key{item.GetChapterID(), item.GetStageID()}] = item",
+		"func (table *STAGETable) GetByID(id uint32) *STAGE {\n\treturn table.byID[id]\n}",
+		"func (table *STAGETable) GetByKey1(chapterID uint32, stageID uint32) *STAGE {",
+	} {
+		assert.Contains(t, code, line)
+	}
+	assert.NotContains(t, code, "atomic")
+
+	// enum column as unique
+	sheet = newTestSheet("ITEM", [][]string{
+		{"unique", "optional"},
+		{"enum:ItemQuality", "string"},
+		{"Quality", "Name"},
+		{"", ""},
+	})
+	pr = newProtoRow(newTestConfig())
+	pr.enums = map[string]*Enum{"ItemQuality": newTestEnum()}
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.GenProto())
+	src, err = pr.GenGo()
+	assert.NoError(t, err)
+	assert.Contains(t, string(src), "func (table *ITEMTable) GetByQuality(quality ITEM_ItemQuality) *ITEM {")
+
+	// no index without unique columns
+	sheet = newTestSheet("NOTE", [][]string{
+		{"required"},
+		{"string"},
+		{"Text"},
+		{""},
+	})
+	pr = newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.GenProto())
+	src, err = pr.GenGo()
+	assert.NoError(t, err)
+	assert.Contains(t, string(src), "func LoadNOTE(path string) (*NOTE_ARRAY, error) {")
+	assert.NotContains(t, string(src), "Table")
+	assert.NotContains(t, string(src), "GetBy")
+}
+
+func TestWriteGo(t *testing.T) {
+	cfg := newTempConfig(t)
+	cfg.GoOutPath = filepath.Join(t.TempDir(), "config")
+	assert.NoError(t, cfg.CheckDirs())
+	cv := newConverter(cfg)
+	assert.NoError(t, cv.ReadSheet("Sample.xlsx", "SAMPLEONE"))
+
+	src, err := ioutil.ReadFile(filepath.Join(cfg.GoOutPath, "sampleone"+GoLoaderExt))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(src), "// Code generated by xlsx2pb. DO NOT EDIT."))
+	assert.Contains(t, string(src), "func LoadSAMPLEONE(path string) (*SAMPLEONE_ARRAY, error) {") // no unique column
+
+	assert.NoError(t, cv.Clean())
+	files, _ := filepath.Glob(filepath.Join(cfg.GoOutPath, "*"))
+	assert.Empty(t, files)
+}
+
+func TestWriteGoIndexChanged(t *testing.T) {
+	cfg := newTempConfig(t)
+	cfg.XlsxPath = t.TempDir()
+	cfg.GoOutPath = filepath.Join(t.TempDir(), "config")
+	assert.NoError(t, cfg.CheckDirs())
+	run := func(attrs string) string {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(cfg.XlsxPath, "stages.csv"), []byte(attrs+`
+uint32,uint32,uint32
+ID,ChapterID,StageID
+,,
+1,1,1
+2,1,2
+`), 0644))
+		cv := newConverter(cfg)
+		assert.NoError(t, cv.readCfgLine("STAGE stages.csv"))
+		_, err := cv.Run(Options{UseCache: true})
+		assert.NoError(t, err)
+
+		src, err := ioutil.ReadFile(filepath.Join(cfg.GoOutPath, "stage"+GoLoaderExt))
+		assert.NoError(t, err)
+		return string(src)
+	}
+
+	assert.NotContains(t, run("unique,optional,optional"), "GetByPos")
+
+	// composite key does not change proto, the loader still gets its index
+	assert.Contains(t, run("unique,unique:pos,unique:pos"), "GetByPos")
+}
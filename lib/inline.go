@@ -0,0 +1,151 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Inline types keep a list in one cell, e.g. "list<int32>" for "1;2;3" or "struct{id:uint32,count:uint32}[]" for "1001:5;1002:3"
+// map types are inline types too, see MapTypePrefix
+const (
+	ListTypePrefix   = "list<"
+	ListTypeSuffix   = ">"
+	StructTypePrefix = "struct{"
+	StructTypeSuffix = "}[]"
+
+	InlineElemSep  = ";" // separates elements in a cell
+	InlineFieldSep = ":" // separates fields of a struct element, also name and type in struct type
+)
+
+// inlineStructSuffix is added to column name as nested message name of inline structs
+const inlineStructSuffix = "Item"
+
+var ErrInlineTypeInvalid = errors.New("invalid inline type")
+
+// isInlineType check if a type keeps a list in one cell
+func isInlineType(typ string) bool {
+	return strings.HasPrefix(typ, ListTypePrefix) || strings.HasPrefix(typ, StructTypePrefix) || strings.HasPrefix(typ, MapTypePrefix)
+}
+
+// newInlineRepeat convert a column with inline type to a repeat
+func (pr *ProtoSheet) newInlineRepeat(val *Val) (*Repeat, error) {
+	if val.proto2Type == Unique || val.uniqueKey != "" {
+		return nil, &SheetError{Col: val.colIdx + 1, Field: val.name, Err: fmt.Errorf("%w, %s column can not be %s", ErrInlineTypeInvalid, val.typ, Unique)}
+	}
+
+	repeat := newRepeat()
+	repeat.name = val.name
+	repeat.comment = val.comment
+	repeat.colIdx = val.colIdx
+	repeat.targets = val.targets
+	repeat.inline = true
+	repeat.required = val.proto2Type == Req
+
+	elemVal := func(name, typ string) (*Val, error) {
+		elem := &Val{proto2Type: Opt, typ: typ}
+		elem.name = name
+		elem.colIdx = val.colIdx
+		if err := elem.parseConstraint(); err != nil {
+			return nil, err
+		}
+		if err := pr.resolveEnum(elem); err != nil {
+			return nil, err
+		}
+		return elem, nil
+	}
+
+	switch {
+	case strings.HasPrefix(val.typ, ListTypePrefix) && strings.HasSuffix(val.typ, ListTypeSuffix):
+		typ := strings.TrimSuffix(strings.TrimPrefix(val.typ, ListTypePrefix), ListTypeSuffix)
+		elem, err := elemVal(val.name, typ)
+		if err != nil {
+			return nil, err
+		}
+		elem.comment = val.comment
+		elem.ref = val.ref // each element refers to another sheet
+		repeat.val = elem
+	case strings.HasPrefix(val.typ, StructTypePrefix) && strings.HasSuffix(val.typ, StructTypeSuffix):
+		if val.ref != nil {
+			return nil, &SheetError{Col: val.colIdx + 1, Field: val.name, Err: fmt.Errorf("%w, struct can not refer to other sheets", ErrInlineTypeInvalid)}
+		}
+
+		repeat.opts = newOptStruct()
+		repeat.opts.name = val.name + inlineStructSuffix
+		names := make(map[string]struct{})
+		for _, part := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(val.typ, StructTypePrefix), StructTypeSuffix), ",") {
+			nameType := strings.SplitN(part, InlineFieldSep, 2)
+			if len(nameType) != 2 || nameType[0] == "" || nameType[1] == "" {
+				return nil, &SheetError{Col: val.colIdx + 1, Field: val.name, Err: fmt.Errorf("%w %s, field should be name%stype", ErrInlineTypeInvalid, val.typ, InlineFieldSep)}
+			}
+			if _, ok := names[nameType[0]]; ok {
+				return nil, &SheetError{Col: val.colIdx + 1, Field: val.name + "." + nameType[0], Err: ErrDuplicateHead}
+			}
+			names[nameType[0]] = struct{}{}
+
+			field, err := elemVal(nameType[0], nameType[1])
+			if err != nil {
+				return nil, err
+			}
+			field.fieldNum = repeat.opts.messageIdx
+			repeat.opts.messageIdx++
+			repeat.opts.fields = append(repeat.opts.fields, field)
+		}
+		repeat.opts.maxLength = len(repeat.opts.fields)
+	case strings.HasPrefix(val.typ, MapTypePrefix) && strings.HasSuffix(val.typ, MapTypeSuffix):
+		if err := pr.parseMapType(repeat, val); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, &SheetError{Col: val.colIdx + 1, Field: val.name, Err: fmt.Errorf("%w %s", ErrInlineTypeInvalid, val.typ)}
+	}
+
+	return repeat, nil
+}
+
+// readInline add elements in a cell to repeated field
+func (pr *ProtoSheet) readInline(m protoreflect.Message, repeat *Repeat, cell string) error {
+	if strings.TrimSpace(cell) == "" {
+		if repeat.required {
+			return ErrRequiredFieldEmpty
+		}
+		return nil
+	}
+	if repeat.key != nil {
+		return pr.readInlineMap(m, repeat, cell)
+	}
+
+	for _, elem := range strings.Split(cell, InlineElemSep) {
+		if strings.TrimSpace(elem) == "" { // allow trailing separator
+			continue
+		}
+
+		if repeat.val != nil {
+			if err := setCell(m, repeat.val, elem); err != nil {
+				return err
+			}
+			pr.addRef(repeat.val, repeat.colIdx, elem)
+			continue
+		}
+
+		// the last field keeps the rest of element, so a string field can contain the separator
+		fields := repeat.opts.fields
+		values := strings.SplitN(elem, InlineFieldSep, len(fields))
+		if last := fields[len(fields)-1]; len(values) == len(fields) && last.typ != "string" && strings.Contains(values[len(values)-1], InlineFieldSep) {
+			return fmt.Errorf("%w %s, %d fields expected", ErrFormatInvalid, elem, len(fields))
+		}
+
+		list := m.Mutable(fieldByNum(m, repeat.fieldNum)).List()
+		item := list.NewElement()
+		for i, value := range values {
+			if err := setCell(item.Message(), fields[i], value); err != nil {
+				return err
+			}
+		}
+		list.Append(item)
+	}
+
+	return nil
+}
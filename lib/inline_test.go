@@ -0,0 +1,98 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitInlineType(t *testing.T) {
+	typ, annotations := splitType("struct{id: uint32, count: uint32}[]")
+	assert.Equal(t, "struct{id:uint32,count:uint32}[]", typ)
+	assert.Empty(t, annotations)
+
+	typ, annotations = splitType("list< uint32 > ref:ITEM.ItemID")
+	assert.Equal(t, "list<uint32>", typ)
+	assert.Equal(t, []string{"ref:ITEM.ItemID"}, annotations)
+}
+
+func TestInlineTypes(t *testing.T) {
+	sheet := newTestSheet("INLINETEST", [][]string{
+		{"unique", "optional", "required", "optional", "optional"},
+		{"uint32", "list<int32>", "struct{id:uint32,count:uint32}[]", "list<enum:ItemQuality>", "struct{id:uint32,url:string}[]"},
+		{"ID", "Levels", "Rewards", "Qualities", "Links"},
+		{"", "levels", "rewards", "", ""},
+		{"1", "1;2;3", "1001:5;1002:3", "Legendary;1", "1:http://a.com:80/b"},
+		{"2", "", "1003;", "", ""},
+	})
+
+	pr := newProtoRow(newTestConfig())
+	pr.enums = map[string]*Enum{"ItemQuality": newTestEnum()}
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.readData(sheet))
+
+	items, err := pr.decodeItems()
+	assert.NoError(t, err)
+	raw, err := json.Marshal(items)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"ID":1,"Levels":[1,2,3],"Rewards":[{"id":1001,"count":5},{"id":1002,"count":3}],"Qualities":["Legendary","Common"],"Links":[{"id":1,"url":"http://a.com:80/b"}]},{"ID":2,"Rewards":[{"id":1003}]}]`, string(raw))
+
+	assert.NoError(t, pr.GenProto())
+	assert.Contains(t, pr.outProto, "  /* levels */")
+	assert.Contains(t, pr.outProto, "  repeated int32 Levels = 2;")
+	assert.Contains(t, pr.outProto, "  message RewardsItem {")
+	assert.Contains(t, pr.outProto, "    optional uint32 count = 2;")
+	assert.Contains(t, pr.outProto, "  repeated RewardsItem Rewards = 3;")
+	assert.Contains(t, pr.outProto, "  repeated ItemQuality Qualities = 4;")
+}
+
+func TestInlineTypeErrors(t *testing.T) {
+	tests := []struct {
+		attr, typ string
+		err       error
+	}{
+		{"unique", "list<int32>", ErrInlineTypeInvalid},
+		{"optional", "list<int32", ErrInlineTypeInvalid},
+		{"optional", "struct{id}[]", ErrInlineTypeInvalid},
+		{"optional", "struct{id:uint32,id:uint32}[]", ErrDuplicateHead},
+		{"optional", "struct{id:uint32} ref:ITEM.ItemID", ErrInlineTypeInvalid},
+	}
+
+	for _, test := range tests {
+		pr := newProtoRow(newTestConfig())
+		err := pr.updateHeads(newTestSheet("INLINETEST", [][]string{
+			{test.attr},
+			{test.typ},
+			{"Field"},
+			{""},
+		}))
+		assert.True(t, errors.Is(err, test.err), test.typ)
+	}
+
+	sheet := newTestSheet("INLINETEST", [][]string{
+		{"optional", "required"},
+		{"struct{id:uint32}[]", "list<int32>"},
+		{"Rewards", "Levels"},
+		{"", ""},
+		{"1:2", "1"},
+		{"1", ""},
+	})
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(sheet))
+	err := pr.readData(sheet)
+	var se *SheetError
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, 5, se.Row)
+	assert.Equal(t, "Rewards", se.Field)
+	assert.True(t, errors.Is(err, ErrFormatInvalid))
+
+	sheet.(*xlsxSheet).sheet.Rows[4].Cells[0].SetString("1")
+	pr = newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(sheet))
+	err = pr.readData(sheet)
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, 6, se.Row)
+	assert.True(t, errors.Is(err, ErrRequiredFieldEmpty))
+}
@@ -0,0 +1,167 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// JSONExt is the default ext of json output, a whole XXX_ARRAY object per sheet
+const JSONExt = ".json"
+
+// JSONLinesExt outputs one item per line instead of a whole XXX_ARRAY object
+const JSONLinesExt = ".jsonl"
+
+// jsonField is a key value pair in jsonObject
+type jsonField struct {
+	name  string
+	value interface{}
+}
+
+// jsonObject keeps fields in the same order as proto define
+type jsonObject []jsonField
+
+// MarshalJSON implements json.Marshaler
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field.name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// decodeItems decode binary data of XXX_ARRAY message
+func (pr *ProtoSheet) decodeItems() ([]jsonObject, error) {
+	if pr.arrayDesc == nil {
+		if err := pr.BuildDescriptor(); err != nil {
+			return nil, err
+		}
+	}
+
+	array := dynamicpb.NewMessage(pr.arrayDesc)
+	if err := (protov2.UnmarshalOptions{AllowPartial: true}).Unmarshal(pr.buf.Bytes(), array); err != nil {
+		return nil, err
+	}
+
+	return decodeArray(array), nil
+}
+
+// decodeArray convert items of XXX_ARRAY message to json objects
+func decodeArray(array protoreflect.Message) []jsonObject {
+	list := array.Get(array.Descriptor().Fields().ByNumber(1)).List()
+	items := make([]jsonObject, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		items = append(items, decodeMessage(list.Get(i).Message()))
+	}
+
+	return items
+}
+
+// decodeMessage convert a message to json object, fields are in the same order as proto define
+func decodeMessage(m protoreflect.Message) jsonObject {
+	fields := m.Descriptor().Fields()
+	obj := make(jsonObject, 0, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if !m.Has(field) {
+			continue
+		}
+
+		var value interface{}
+		if field.IsMap() {
+			value = decodeMap(field, m.Get(field).Map())
+		} else if field.IsList() {
+			list := m.Get(field).List()
+			values := make([]interface{}, 0, list.Len())
+			for j := 0; j < list.Len(); j++ {
+				values = append(values, decodeValue(field, list.Get(j)))
+			}
+			value = values
+		} else {
+			value = decodeValue(field, m.Get(field))
+		}
+		obj = append(obj, jsonField{name: string(field.Name()), value: value})
+	}
+
+	return obj
+}
+
+// decodeValue is the reverse of readCell, enums are output as names
+func decodeValue(field protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch field.Kind() {
+	case protoreflect.MessageKind:
+		return decodeMessage(v.Message())
+	case protoreflect.EnumKind:
+		if ev := field.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return int32(v.Enum())
+	}
+
+	return v.Interface()
+}
+
+// WriteJSON output data as json to "./json/sheetname.json", or json lines if ext is ".jsonl"
+func (pr *ProtoSheet) WriteJSON() error {
+	items, err := pr.decodeItems()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(pr.cfg.JSONOutPath, strings.ToLower(pr.Name)+pr.cfg.JSONOutExt))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if pr.cfg.JSONOutExt == JSONLinesExt {
+		for _, item := range items {
+			line, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(append(line, '\n')); err != nil {
+				return err
+			}
+		}
+	} else {
+		raw, err := json.MarshalIndent(jsonObject{{name: "items", value: items}}, "", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(raw, '\n')); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeItems(t *testing.T) {
+	sheet := newTestSheet("JSONTEST", [][]string{
+		{"unique", "optional", "optional", "optional", "optional", "repeated", "optional", "optional"},
+		{"int32", "sint64", "bool", "float", "string", "2", "uint32", "uint32"},
+		{"ID", "Offset", "IsOpen", "Rate", "Name", "", "Items", "Items"},
+		{"", "", "", "", "", "", "", ""},
+		{"-1", "-3", "TRUE", "0.5", " first ", "2", "7", "8"},
+		{"2", "", "no", "", "second", "", "", ""},
+	})
+
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.readData(sheet))
+
+	items, err := pr.decodeItems()
+	assert.NoError(t, err)
+
+	raw, err := json.Marshal(items)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"ID":-1,"Offset":-3,"IsOpen":true,"Rate":0.5,"Name":"first","Items":[7,8]},{"ID":2,"IsOpen":false,"Name":"second"}]`, string(raw))
+}
+
+func TestWriteJSON(t *testing.T) {
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(testSheet))
+	assert.NoError(t, pr.readData(testSheet))
+
+	pr.cfg.JSONOutPath = "../test/"
+	for _, ext := range []string{".json", JSONLinesExt} {
+		pr.cfg.JSONOutExt = ext
+		assert.NoError(t, pr.WriteJSON())
+
+		tarFile := "../test/sampleone" + ext
+		raw, err := ioutil.ReadFile(tarFile)
+		assert.NoError(t, err)
+		os.Remove(tarFile)
+
+		if ext == JSONLinesExt {
+			lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+			for _, line := range lines {
+				assert.True(t, json.Valid([]byte(line)))
+			}
+			assert.Contains(t, lines[0], `"SampleID":`)
+		} else {
+			assert.True(t, json.Valid(raw))
+			assert.True(t, strings.HasPrefix(string(raw), "{\n  \"items\": ["))
+		}
+	}
+}
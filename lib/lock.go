@@ -0,0 +1,263 @@
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DefaultLockFile is saved next to cache file if lock file is not set in config
+const DefaultLockFile = "fieldlock.json"
+
+// FieldLock keeps field numbers of generated messages stable between runs
+// It should be committed with proto files, so inserting a column will not renumber other fields
+type FieldLock struct {
+	Messages map[string]*MessageLock `json:"messages"` // map[sheetName]message lock
+}
+
+// MessageLock contains field numbers of a message and its nested messages
+type MessageLock struct {
+	Fields        map[string]int32        `json:"fields"` // map[fieldName]field number
+	ReservedNums  []int32                 `json:"reserved_nums,omitempty"`
+	ReservedNames []string                `json:"reserved_names,omitempty"`
+	Nested        map[string]*MessageLock `json:"nested,omitempty"` // map[structName]message lock
+}
+
+func newFieldLock() *FieldLock {
+	return &FieldLock{Messages: make(map[string]*MessageLock)}
+}
+
+func newMessageLock() *MessageLock {
+	return &MessageLock{
+		Fields: make(map[string]int32),
+		Nested: make(map[string]*MessageLock),
+	}
+}
+
+// loadFieldLock read field lock file, a new lock is used if file does not exist
+func (cv *Converter) loadFieldLock() error {
+	cv.fieldLock = newFieldLock()
+
+	rawData, err := ioutil.ReadFile(cv.cfg.LockFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(rawData, cv.fieldLock)
+}
+
+// saveFieldLock write field lock file
+func (cv *Converter) saveFieldLock() error {
+	rawData, err := json.MarshalIndent(cv.fieldLock, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cv.cfg.LockFile), 0777); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(cv.cfg.LockFile, rawData, 0644)
+}
+
+// messageLock returns a copy of message lock, changes are saved by updateMessageLock after sheet is converted
+func (cv *Converter) messageLock(sheetName string) *MessageLock {
+	cv.mutex.Lock()
+	defer cv.mutex.Unlock()
+
+	if lock, ok := cv.fieldLock.Messages[sheetName]; ok {
+		return lock.clone()
+	}
+
+	return newMessageLock()
+}
+
+// updateMessageLock save message lock of a sheet converted in current run
+func (cv *Converter) updateMessageLock(sheetName string, lock *MessageLock) {
+	cv.mutex.Lock()
+	defer cv.mutex.Unlock()
+
+	cv.fieldLock.Messages[sheetName] = lock
+}
+
+func (l *MessageLock) clone() *MessageLock {
+	c := newMessageLock()
+	for name, num := range l.Fields {
+		c.Fields[name] = num
+	}
+	c.ReservedNums = append(c.ReservedNums, l.ReservedNums...)
+	c.ReservedNames = append(c.ReservedNames, l.ReservedNames...)
+	for name, nested := range l.Nested {
+		c.Nested[name] = nested.clone()
+	}
+
+	return c
+}
+
+// number returns locked field number, a new field gets a number never used before
+func (l *MessageLock) number(name string) int32 {
+	if num, ok := l.Fields[name]; ok {
+		return num
+	}
+
+	var maxNum int32
+	for _, num := range l.Fields {
+		if num > maxNum {
+			maxNum = num
+		}
+	}
+	for _, num := range l.ReservedNums {
+		if num > maxNum {
+			maxNum = num
+		}
+	}
+
+	// a removed field is added back, its old number is still reserved
+	for i, reserved := range l.ReservedNames {
+		if reserved == name {
+			l.ReservedNames = append(l.ReservedNames[:i], l.ReservedNames[i+1:]...)
+			break
+		}
+	}
+
+	l.Fields[name] = maxNum + 1
+
+	return maxNum + 1
+}
+
+// nested returns lock of a nested message
+func (l *MessageLock) nested(name string) *MessageLock {
+	if l.Nested == nil {
+		l.Nested = make(map[string]*MessageLock)
+	}
+	if _, ok := l.Nested[name]; !ok {
+		l.Nested[name] = newMessageLock()
+	}
+
+	return l.Nested[name]
+}
+
+// rename move a locked field to a new name, struct fields were locked by type name before they were locked by field name
+func (l *MessageLock) rename(oldName, newName string) {
+	num, ok := l.Fields[oldName]
+	if !ok || oldName == newName {
+		return
+	}
+	if _, ok := l.Fields[newName]; !ok {
+		l.Fields[newName] = num
+		delete(l.Fields, oldName)
+	}
+}
+
+// reserveUnused move fields not in use to reserved
+func (l *MessageLock) reserveUnused(used map[string]struct{}) {
+	for name, num := range l.Fields {
+		if _, ok := used[name]; ok {
+			continue
+		}
+		l.ReservedNums = append(l.ReservedNums, num)
+		l.ReservedNames = append(l.ReservedNames, name)
+		delete(l.Fields, name)
+	}
+
+	sort.Slice(l.ReservedNums, func(i, j int) bool { return l.ReservedNums[i] < l.ReservedNums[j] })
+	sort.Strings(l.ReservedNames)
+}
+
+// applyFieldLock replace field numbers generated in column order with locked ones
+// new fields are numbered in column order after all locked fields
+func (pr *ProtoSheet) applyFieldLock() {
+	if pr.lock == nil {
+		return
+	}
+
+	// sheet message fields are locked by the names in proto, structs are named by comment
+	var fields []lockedField
+	for _, val := range pr.vars {
+		fields = append(fields, lockedField{name: val.name, info: &val.CommonInfo})
+	}
+	for _, optS := range pr.optStructs {
+		pr.lock.rename(optS.name, optS.fieldName())
+		fields = append(fields, lockedField{name: optS.fieldName(), info: &optS.CommonInfo})
+		lockVals(pr.lock.nested(optS.name), optS.fields)
+	}
+	for _, repeat := range pr.repeats {
+		pr.lock.rename(repeat.name, repeat.fieldName())
+		fields = append(fields, lockedField{name: repeat.fieldName(), info: &repeat.CommonInfo})
+		if repeat.opts != nil {
+			lockVals(pr.lock.nested(repeat.opts.name), repeat.opts.fields)
+		}
+	}
+
+	lockFields(pr.lock, fields)
+
+	for _, repeat := range pr.repeats {
+		if repeat.val != nil {
+			repeat.val.fieldNum = repeat.fieldNum
+		}
+	}
+}
+
+// reserveUnusedFields reserve locked fields removed from sheet, called after all sheets are read
+func (pr *ProtoSheet) reserveUnusedFields() {
+	if pr.lock == nil {
+		return
+	}
+
+	used := make(map[string]struct{}, len(pr.fieldMap))
+	for _, val := range pr.vars {
+		used[val.name] = struct{}{}
+	}
+	for _, optS := range pr.optStructs {
+		used[optS.fieldName()] = struct{}{}
+	}
+	for _, repeat := range pr.repeats {
+		used[repeat.fieldName()] = struct{}{}
+	}
+	pr.lock.reserveUnused(used)
+
+	structs := make(map[string][]*Val)
+	for _, optS := range pr.optStructs {
+		structs[optS.name] = optS.fields
+	}
+	for _, repeat := range pr.repeats {
+		if repeat.opts != nil {
+			structs[repeat.opts.name] = repeat.opts.fields
+		}
+	}
+	for name, vals := range structs {
+		used := make(map[string]struct{}, len(vals))
+		for _, val := range vals {
+			used[val.name] = struct{}{}
+		}
+		pr.lock.nested(name).reserveUnused(used)
+	}
+}
+
+// lockedField is a field numbered by lock
+type lockedField struct {
+	name string // field name in proto
+	info *CommonInfo
+}
+
+func lockVals(lock *MessageLock, vals []*Val) {
+	fields := make([]lockedField, 0, len(vals))
+	for _, val := range vals {
+		fields = append(fields, lockedField{name: val.name, info: &val.CommonInfo})
+	}
+
+	lockFields(lock, fields)
+}
+
+// lockFields keep column order for new fields
+func lockFields(lock *MessageLock, fields []lockedField) {
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].info.fieldNum < fields[j].info.fieldNum })
+	for _, field := range fields {
+		field.info.fieldNum = int(lock.number(field.name))
+	}
+}
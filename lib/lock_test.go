@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func genLockedProto(t *testing.T, lock *MessageLock, sheet Sheet) *ProtoSheet {
+	pr := newProtoRow(newTestConfig())
+	pr.lock = lock
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.readData(sheet))
+	pr.reserveUnusedFields()
+	assert.NoError(t, pr.GenProto())
+
+	return pr
+}
+
+func TestFieldLock(t *testing.T) {
+	lock := newMessageLock()
+	pr := genLockedProto(t, lock, newTestSheet("LOCKTEST", [][]string{
+		{"required", "optional", "optional", "repeated", "optional_struct", "optional", "optional"},
+		{"uint32", "string", "uint32", "1", "2", "uint32", "uint32"},
+		{"ID", "Name", "Count", "", "Item", "ItemID", "Num"},
+		{"", "", "", "", "", "", ""},
+		{"1", "first", "3", "1", "", "1001", "2"},
+	}))
+	assert.Equal(t, map[string]int32{"ID": 1, "Name": 2, "Count": 3, "items": 4}, lock.Fields)
+	assert.Equal(t, map[string]int32{"ItemID": 1, "Num": 2}, lock.Nested["Item"].Fields)
+	assert.NotContains(t, pr.outProto, "  reserved 2;")
+
+	// insert a column before Name, remove Count and swap columns in struct
+	pr = genLockedProto(t, lock, newTestSheet("LOCKTEST", [][]string{
+		{"required", "optional", "optional", "repeated", "optional_struct", "optional", "optional"},
+		{"uint32", "int32", "string", "1", "2", "uint32", "uint32"},
+		{"ID", "Level", "Name", "", "Item", "Num", "ItemID"},
+		{"", "", "", "", "", "", ""},
+		{"1", "5", "first", "1", "", "2", "1001"},
+	}))
+	assert.Equal(t, 2, pr.vars[2].fieldNum)
+	assert.Equal(t, 5, pr.vars[1].fieldNum)
+	assert.Equal(t, 4, pr.repeats[0].fieldNum)
+	assert.Equal(t, 2, pr.repeats[0].opts.fields[0].fieldNum)
+	assert.Equal(t, []int32{3}, lock.ReservedNums)
+	assert.Equal(t, []string{"Count"}, lock.ReservedNames)
+	assert.Contains(t, pr.outProto, "  reserved 3;")
+	assert.Contains(t, pr.outProto, "  reserved \"Count\";")
+	assert.Contains(t, pr.outProto, "  optional int32 Level = 5 [default = 0];")
+
+	// field added back gets a new number
+	assert.Equal(t, int32(6), lock.number("Count"))
+	assert.Empty(t, lock.ReservedNames)
+}
+
+func TestFieldLockStruct(t *testing.T) {
+	// struct locked by type name before is moved to its field name
+	lock := newMessageLock()
+	lock.Fields = map[string]int32{"ID": 1, "Reward": 2}
+	pr := genLockedProto(t, lock, newTestSheet("LOCKTEST", [][]string{
+		{"required", "optional_struct", "optional", "optional"},
+		{"uint32", "2", "uint32", "uint32"},
+		{"ID", "Reward", "ItemID", "Num"},
+		{"", "firstReward", "", ""},
+		{"1", "", "1001", "2"},
+	}))
+	assert.Equal(t, map[string]int32{"ID": 1, "firstReward": 2}, lock.Fields)
+	assert.Contains(t, pr.outProto, "  optional Reward firstReward = 2;")
+
+	// removed struct reserves the field name clients saw
+	pr = genLockedProto(t, lock, newTestSheet("LOCKTEST", [][]string{
+		{"required"},
+		{"uint32"},
+		{"ID"},
+		{""},
+		{"1"},
+	}))
+	assert.Equal(t, []int32{2}, lock.ReservedNums)
+	assert.Equal(t, []string{"firstReward"}, lock.ReservedNames)
+	assert.Contains(t, pr.outProto, "  reserved \"firstReward\";")
+}
+
+func TestFieldLockFile(t *testing.T) {
+	cv := newConverter(newTestConfig())
+	cv.cfg.LockFile = "../test/fieldlocktest.json"
+	defer os.Remove(cv.cfg.LockFile)
+
+	assert.NoError(t, cv.loadFieldLock())
+	lock := cv.messageLock("SAMPLEONE")
+	lock.number("SampleID")
+	lock.nested("StructName").number("RewardID")
+	cv.updateMessageLock("SAMPLEONE", lock)
+	assert.NoError(t, cv.saveFieldLock())
+
+	cv = newConverter(newTestConfig())
+	cv.cfg.LockFile = "../test/fieldlocktest.json"
+	assert.NoError(t, cv.loadFieldLock())
+	assert.Equal(t, lock, cv.messageLock("SAMPLEONE"))
+}
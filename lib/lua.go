@@ -0,0 +1,287 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// LuaExt is the ext of lua modules, each returns items of a sheet keyed by the first unique column
+const LuaExt = ".lua"
+
+// luaMaxInt is the max integer can be kept exactly in a lua number, which is a double before lua 5.3
+const luaMaxInt = 1 << 53
+
+var ErrLuaIntRange = errors.New("integer out of lua number range")
+
+var luaKeywords = map[string]struct{}{
+	"and": {}, "break": {}, "do": {}, "else": {}, "elseif": {}, "end": {}, "false": {}, "for": {}, "function": {},
+	"goto": {}, "if": {}, "in": {}, "local": {}, "nil": {}, "not": {}, "or": {}, "repeat": {}, "return": {},
+	"then": {}, "true": {}, "until": {}, "while": {},
+}
+
+// luaKeyField returns field of the first unique column, nil if sheet has no unique column
+func (pr *ProtoSheet) luaKeyField() protoreflect.FieldDescriptor {
+	for _, val := range pr.vars {
+		if val.proto2Type == Unique {
+			return pr.msgDesc.Fields().ByName(protoreflect.Name(val.name))
+		}
+	}
+
+	return nil
+}
+
+// MarshalLua encode data as a lua module, values are decoded from binary data so they are the same as read by readCell
+// items are keyed by the first unique column, or a sequence if there is no unique column
+func (pr *ProtoSheet) MarshalLua() ([]byte, error) {
+	if pr.arrayDesc == nil {
+		if err := pr.BuildDescriptor(); err != nil {
+			return nil, err
+		}
+	}
+
+	array := dynamicpb.NewMessage(pr.arrayDesc)
+	if err := (protov2.UnmarshalOptions{AllowPartial: true}).Unmarshal(pr.buf.Bytes(), array); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "-- Code generated by xlsx2pb. DO NOT EDIT.\n-- source: %s\n\nreturn {\n", pr.fileDesc.GetName())
+
+	keyField := pr.luaKeyField()
+	list := array.Get(pr.arrayDesc.Fields().ByNumber(1)).List()
+	for i := 0; i < list.Len(); i++ {
+		item := list.Get(i).Message()
+		buf.WriteString(INDENT)
+		if keyField != nil {
+			key, err := luaValue(keyField, item.Get(keyField))
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&buf, "[%s] = ", key)
+		}
+
+		value, err := luaMessage(item)
+		if err != nil {
+			return nil, fmt.Errorf("item %d, %w", i+1, err)
+		}
+		buf.WriteString(value + ",\n")
+	}
+	buf.WriteString("}\n")
+
+	return buf.Bytes(), nil
+}
+
+// luaMessage convert a message to table, fields are in the same order as proto define
+// unset fields are omitted unless they have a default value other than zero
+func luaMessage(m protoreflect.Message) (string, error) {
+	fields := m.Descriptor().Fields()
+	parts := make([]string, 0, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if !m.Has(field) && (field.IsList() || field.IsMap() || field.Kind() == protoreflect.MessageKind || isZeroValue(field.Default())) {
+			continue
+		}
+
+		var value string
+		var err error
+		switch {
+		case field.IsMap():
+			value, err = luaMap(field, m.Get(field).Map())
+		case field.IsList():
+			list := m.Get(field).List()
+			values := make([]string, 0, list.Len())
+			for j := 0; j < list.Len() && err == nil; j++ {
+				var v string
+				v, err = luaValue(field, list.Get(j))
+				values = append(values, v)
+			}
+			value = luaTable(values)
+		default:
+			value, err = luaValue(field, m.Get(field))
+		}
+		if err != nil {
+			return "", fmt.Errorf("field %s, %w", field.Name(), err)
+		}
+
+		parts = append(parts, luaFieldName(string(field.Name()))+" = "+value)
+	}
+
+	return luaTable(parts), nil
+}
+
+// luaMap convert a map to table, keys are sorted so output is stable
+func luaMap(field protoreflect.FieldDescriptor, m protoreflect.Map) (string, error) {
+	keys := sortedMapKeys(m)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		k, err := luaValue(field.MapKey(), key.Value())
+		if err != nil {
+			return "", err
+		}
+		v, err := luaValue(field.MapValue(), m.Get(key))
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, fmt.Sprintf("[%s] = %s", k, v))
+	}
+
+	return luaTable(parts), nil
+}
+
+// luaValue returns lua literal of a value, enums are output as numbers the same as binary data
+func luaValue(field protoreflect.FieldDescriptor, v protoreflect.Value) (string, error) {
+	switch field.Kind() {
+	case protoreflect.MessageKind:
+		return luaMessage(v.Message())
+	case protoreflect.EnumKind:
+		return strconv.Itoa(int(v.Enum())), nil
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(v.Bool()), nil
+	case protoreflect.StringKind:
+		return luaQuote(v.String()), nil
+	case protoreflect.BytesKind:
+		return luaQuote(string(v.Bytes())), nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return luaFloat(v.Float(), field.Kind() == protoreflect.FloatKind), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if v.Uint() > luaMaxInt {
+			return "", fmt.Errorf("%w, %d", ErrLuaIntRange, v.Uint())
+		}
+		return strconv.FormatUint(v.Uint(), 10), nil
+	}
+
+	if n := v.Int(); n > luaMaxInt || n < -luaMaxInt {
+		return "", fmt.Errorf("%w, %d", ErrLuaIntRange, n)
+	}
+
+	return strconv.FormatInt(v.Int(), 10), nil
+}
+
+// luaFloat format float the same as json output, integral values keep ".0" so they are floats in lua 5.3
+func luaFloat(f float64, isFloat32 bool) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "math.huge"
+	case math.IsInf(f, -1):
+		return "-math.huge"
+	case math.IsNaN(f):
+		return "0/0"
+	}
+
+	bitSize := 64
+	if isFloat32 {
+		bitSize = 32
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+
+	return s
+}
+
+func luaTable(parts []string) string {
+	if len(parts) == 0 {
+		return "{}"
+	}
+
+	return "{ " + strings.Join(parts, ", ") + " }"
+}
+
+// luaFieldName returns key of a field in table, names which are not identifiers are quoted
+func luaFieldName(name string) string {
+	if _, ok := luaKeywords[name]; !ok && isLuaIdent(name) {
+		return name
+	}
+
+	return "[" + luaQuote(name) + "]"
+}
+
+func isLuaIdent(name string) bool {
+	for i, c := range name {
+		if c != '_' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+
+	return name != ""
+}
+
+// luaQuote returns a lua string literal, control characters are escaped in decimal
+func luaQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&sb, `\%03d`, c)
+				continue
+			}
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+
+	return sb.String()
+}
+
+// isZeroValue check if a default value is zero, unset fields with zero default are not output
+func isZeroValue(v protoreflect.Value) bool {
+	switch v := v.Interface().(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case string:
+		return v == ""
+	case []byte:
+		return len(v) == 0
+	case protoreflect.EnumNumber:
+		return v == 0
+	case int32:
+		return v == 0
+	case int64:
+		return v == 0
+	case uint32:
+		return v == 0
+	case uint64:
+		return v == 0
+	case float32:
+		return v == 0
+	case float64:
+		return v == 0
+	}
+
+	return false
+}
+
+// WriteLua output data as a lua module to "./lua/sheetname.lua"
+func (pr *ProtoSheet) WriteLua() error {
+	raw, err := pr.MarshalLua()
+	if err != nil {
+		return fmt.Errorf("export %s to lua fail, %v", pr.Name, err)
+	}
+
+	return ioutil.WriteFile(filepath.Join(pr.cfg.LuaOutPath, strings.ToLower(pr.Name)+LuaExt), raw, 0644)
+}
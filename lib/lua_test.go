@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"errors"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLuaNames(t *testing.T) {
+	assert.Equal(t, `"a\"b\\c\n\001中"`, luaQuote("a\"b\\c\n\x01中"))
+	assert.Equal(t, "Name", luaFieldName("Name"))
+	assert.Equal(t, `["end"]`, luaFieldName("end"))
+	assert.Equal(t, `["1st"]`, luaFieldName("1st"))
+	assert.Equal(t, "2.0", luaFloat(2, false))
+	assert.Equal(t, "0.1", luaFloat(float64(float32(0.1)), true))
+	assert.Equal(t, "1e+21", luaFloat(1e21, false))
+	assert.Equal(t, "-math.huge", luaFloat(math.Inf(-1), false))
+}
+
+func TestMarshalLua(t *testing.T) {
+	sheet := newTestSheet("LUATEST", [][]string{
+		{"unique", "optional", "optional", "optional", "optional", "optional", "optional"},
+		{"int32", "enum:ItemQuality", "string", "float", "list<sint32>", "map<string, uint32>", "uint32"},
+		{"ID", "Quality", "Name", "Rate", "Levels", "Texts", "Count=5"},
+		{"", "", "", "", "", "", ""},
+		{"-1", "Rare", " first ", "2", "1;-2", "b:2;a:1", ""},
+		{"2", "", "", "", "", "", "0"},
+	})
+
+	pr := newProtoRow(newTestConfig())
+	pr.enums = map[string]*Enum{"ItemQuality": newTestEnum()}
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.readData(sheet))
+
+	raw, err := pr.MarshalLua()
+	assert.NoError(t, err)
+	lines := strings.Split(string(raw), "\n")
+	assert.Equal(t, "-- Code generated by xlsx2pb. DO NOT EDIT.", lines[0])
+	assert.Equal(t, "return {", lines[3])
+	assert.Equal(t, `  [-1] = { ID = -1, Quality = 2, Name = "first", Rate = 2.0, Count = 5, Levels = { 1, -2 }, Texts = { ["a"] = 1, ["b"] = 2 } },`, lines[4])
+	assert.Equal(t, `  [2] = { ID = 2, Quality = 1, Count = 0 },`, lines[5])
+	assert.Equal(t, "}", lines[6])
+
+	// a sequence without unique columns, integers which lua can not keep are refused
+	sheet = newTestSheet("LUASEQ", [][]string{
+		{"optional"},
+		{"uint64"},
+		{"Num"},
+		{""},
+		{"9007199254740992"},
+		{"9007199254740994"},
+	})
+	pr = newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.readData(sheet))
+	_, err = pr.MarshalLua()
+	assert.True(t, errors.Is(err, ErrLuaIntRange))
+
+	pr.buf.Reset()
+	assert.NoError(t, pr.readData(newTestSheet("LUASEQ", [][]string{
+		{"optional"},
+		{"uint64"},
+		{"Num"},
+		{""},
+		{"1"},
+	})))
+	raw, err = pr.MarshalLua()
+	assert.NoError(t, err)
+	assert.Contains(t, string(raw), "return {\n  { Num = 1 },\n}\n")
+}
+
+func TestWriteLua(t *testing.T) {
+	cfg := newTempConfig(t)
+	cfg.LuaOutPath = t.TempDir()
+	cv := newConverter(cfg)
+	assert.NoError(t, cv.ReadSheet("Sample.xlsx", "SAMPLEONE"))
+
+	raw, err := ioutil.ReadFile(filepath.Join(cfg.LuaOutPath, "sampleone"+LuaExt))
+	assert.NoError(t, err)
+	assert.Contains(t, string(raw), "return {\n  { SampleID = 64, ")
+
+	assert.NoError(t, cv.Clean())
+	files, _ := filepath.Glob(filepath.Join(cfg.LuaOutPath, "*"))
+	assert.Empty(t, files)
+}
@@ -0,0 +1,208 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Map types keep key value pairs in one cell, e.g. "map<string,int32>" for "a:1;b:2"
+const (
+	MapTypePrefix = "map<"
+	MapTypeSuffix = ">"
+)
+
+var ErrDuplicateMapKey = errors.New("duplicate map key")
+
+// mapKeyTypes are scalar types allowed as map key in proto
+var mapKeyTypes = map[string]struct{}{
+	"int32": {}, "int64": {}, "uint32": {}, "uint64": {}, "sint32": {}, "sint64": {}, "string": {}, "bool": {},
+}
+
+// parseMapType set key and value of a repeat from type like "map<string,int32>"
+func (pr *ProtoSheet) parseMapType(repeat *Repeat, val *Val) error {
+	kv := strings.Split(strings.TrimSuffix(strings.TrimPrefix(val.typ, MapTypePrefix), MapTypeSuffix), ",")
+	if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+		return &SheetError{Col: val.colIdx + 1, Field: val.name, Err: fmt.Errorf("%w %s, should be %skey,value%s", ErrInlineTypeInvalid, val.typ, MapTypePrefix, MapTypeSuffix)}
+	}
+
+	repeat.key = &Val{proto2Type: Req, typ: kv[0]}
+	repeat.key.name = val.name
+	repeat.key.colIdx = val.colIdx
+	repeat.key.ref = val.ref // keys refer to another sheet, e.g. drop weights of items
+
+	repeat.val = &Val{proto2Type: Opt, typ: kv[1]}
+	repeat.val.name = val.name
+	repeat.val.colIdx = val.colIdx
+
+	for _, v := range []*Val{repeat.key, repeat.val} {
+		if err := v.parseConstraint(); err != nil {
+			return err
+		}
+	}
+
+	return pr.resolveEnum(repeat.val)
+}
+
+// addMapColumn add key and value columns of a map in turn, e.g. "map 2 | key | value | key | value"
+func (rp *Repeat) addMapColumn(val *Val) {
+	var last *Val
+	if (val.colIdx-rp.colIdx)%2 == 1 {
+		val.proto2Type = Req
+		last, rp.key = rp.key, val
+	} else {
+		last, rp.val = rp.val, val
+	}
+
+	if last != nil && !isSameVal(val, last) {
+		log.Printf("<Warning> map %s columns differ, current %+v, last %+v\n", rp.name, val, last)
+	}
+	rp.curLength--
+}
+
+// mapEntryName returns name of the nested message generated for a map field, the same as protoc
+func mapEntryName(fieldName string) string {
+	var sb strings.Builder
+	upperNext := true
+	for _, r := range fieldName {
+		switch {
+		case r == '_':
+			upperNext = true
+		case upperNext:
+			sb.WriteRune(unicode.ToUpper(r))
+			upperNext = false
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String() + "Entry"
+}
+
+// addMapEntry add the nested map entry message of a map field
+func (pr *ProtoSheet) addMapEntry(msg *descriptorpb.DescriptorProto, repeat *Repeat) error {
+	if _, ok := mapKeyTypes[repeat.key.typ]; !ok {
+		return &SheetError{Col: repeat.colIdx + 1, Field: repeat.name, Err: fmt.Errorf("%w, %s can not be map key", ErrInlineTypeInvalid, repeat.key.typ)}
+	}
+
+	entry := &descriptorpb.DescriptorProto{
+		Name:    protov2.String(mapEntryName(repeat.name)),
+		Options: &descriptorpb.MessageOptions{MapEntry: protov2.Bool(true)},
+	}
+	for i, val := range []*Val{repeat.key, repeat.val} {
+		field, err := pr.fieldDescriptor(val, []string{"key", "value"}[i], i+1, false)
+		if err != nil {
+			return err
+		}
+		field.Label = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+		field.DefaultValue = nil
+		entry.Field = append(entry.Field, field)
+	}
+	msg.NestedType = append(msg.NestedType, entry)
+
+	return nil
+}
+
+// setMapEntry add a key value pair to map field, empty value is set as default value
+func (pr *ProtoSheet) setMapEntry(m protoreflect.Message, repeat *Repeat, keyCell, valueCell string) error {
+	k, _, err := readCell(repeat.key, keyCell)
+	if err != nil {
+		return err
+	}
+
+	field := fieldByNum(m, repeat.fieldNum)
+	entries := m.Mutable(field).Map()
+	if entries.Has(k.MapKey()) {
+		return fmt.Errorf("%w %s", ErrDuplicateMapKey, strings.TrimSpace(keyCell))
+	}
+
+	v, ok, err := readCell(repeat.val, valueCell)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		v = field.MapValue().Default()
+	}
+	entries.Set(k.MapKey(), v)
+
+	return nil
+}
+
+// readInlineMap add key value pairs in a cell to map field
+func (pr *ProtoSheet) readInlineMap(m protoreflect.Message, repeat *Repeat, cell string) error {
+	for _, elem := range strings.Split(cell, InlineElemSep) {
+		if strings.TrimSpace(elem) == "" {
+			continue
+		}
+
+		kv := strings.SplitN(elem, InlineFieldSep, 2) // value may contain separator, e.g. localized text
+		if len(kv) == 1 {
+			kv = append(kv, "")
+		}
+		if err := pr.setMapEntry(m, repeat, kv[0], kv[1]); err != nil {
+			return err
+		}
+		pr.addRef(repeat.key, repeat.colIdx, kv[0])
+	}
+
+	return nil
+}
+
+// readMap add key value pairs in columns to map field, count of pairs is in the map column
+func (pr *ProtoSheet) readMap(m protoreflect.Message, repeat *Repeat, row []string) error {
+	cell := func(colIdx int) string {
+		if colIdx < len(row) {
+			return row[colIdx]
+		}
+		return ""
+	}
+
+	for count := 0; count < repeat.getCount(row); count++ {
+		keyIdx := repeat.colIdx + 2*count + 1
+		if strings.TrimSpace(cell(keyIdx)) == "" && strings.TrimSpace(cell(keyIdx+1)) == "" {
+			continue
+		}
+		if err := pr.setMapEntry(m, repeat, cell(keyIdx), cell(keyIdx+1)); err != nil {
+			return &SheetError{Col: keyIdx + 1, Field: repeat.name, Err: err}
+		}
+		pr.addRef(repeat.key, keyIdx, cell(keyIdx))
+		pr.addRef(repeat.val, keyIdx+1, cell(keyIdx+1))
+	}
+
+	return nil
+}
+
+// decodeMap convert a map to json object, keys are sorted
+func decodeMap(field protoreflect.FieldDescriptor, entries protoreflect.Map) jsonObject {
+	keys := make([]protoreflect.MapKey, 0, entries.Len())
+	entries.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Slice(keys, func(i, j int) bool {
+		switch keys[i].Interface().(type) {
+		case string:
+			return keys[i].String() < keys[j].String()
+		case bool:
+			return !keys[i].Bool() && keys[j].Bool()
+		case int32, int64:
+			return keys[i].Int() < keys[j].Int()
+		default:
+			return keys[i].Uint() < keys[j].Uint()
+		}
+	})
+
+	obj := make(jsonObject, 0, len(keys))
+	for _, k := range keys {
+		obj = append(obj, jsonField{name: k.String(), value: decodeValue(field.MapValue(), entries.Get(k))})
+	}
+
+	return obj
+}
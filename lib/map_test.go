@@ -0,0 +1,77 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapEntryName(t *testing.T) {
+	assert.Equal(t, "WeightsEntry", mapEntryName("Weights"))
+	assert.Equal(t, "DropWeightsEntry", mapEntryName("drop_weights"))
+}
+
+func TestMapTypes(t *testing.T) {
+	sheet := newTestSheet("MAPTEST", [][]string{
+		{"unique", "optional", "map", "optional", "optional", "optional", "optional"},
+		{"uint32", "map<string, string>", "2", "uint32", "int32", "uint32", "int32"},
+		{"ID", "Texts", "Weights", "ItemID", "Weight", "ItemID", "Weight"},
+		{"", "texts", "weights", "", "", "", ""},
+		{"1", "en:Hello: world;zh:", "2", "1002", "-5", "1001", "10"},
+		{"2", "", "1", "1003"},
+	})
+
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.readData(sheet))
+
+	items, err := pr.decodeItems()
+	assert.NoError(t, err)
+	raw, err := json.Marshal(items)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"ID":1,"Texts":{"en":"Hello: world","zh":""},"Weights":{"1001":10,"1002":-5}},{"ID":2,"Weights":{"1003":0}}]`, string(raw))
+
+	assert.NoError(t, pr.GenProto())
+	assert.Contains(t, pr.outProto, "  map<string, string> Texts = 2;")
+	assert.Contains(t, pr.outProto, "  /* weights */")
+	assert.Contains(t, pr.outProto, "  map<uint32, int32> Weights = 3;")
+	assert.NotContains(t, pr.outProto, "  message TextsEntry {")
+}
+
+func TestMapErrors(t *testing.T) {
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(newTestSheet("MAPTEST", [][]string{
+		{"optional"},
+		{"map<float,int32>"},
+		{"Weights"},
+		{""},
+	})))
+	assert.True(t, errors.Is(pr.BuildDescriptor(), ErrInlineTypeInvalid))
+
+	pr = newProtoRow(newTestConfig())
+	err := pr.updateHeads(newTestSheet("MAPTEST", [][]string{
+		{"map", "optional"},
+		{"1", "uint32"},
+		{"Weights", "ItemID"},
+		{"", ""},
+	}))
+	assert.Error(t, err)
+
+	sheet := newTestSheet("MAPTEST", [][]string{
+		{"optional"},
+		{"map<uint32,int32>"},
+		{"Weights"},
+		{""},
+		{"1001:5;1001:3"},
+	})
+	pr = newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(sheet))
+	err = pr.readData(sheet)
+	assert.True(t, errors.Is(err, ErrDuplicateMapKey))
+	var se *SheetError
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, 5, se.Row)
+	assert.Equal(t, "Weights", se.Field)
+}
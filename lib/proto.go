@@ -6,42 +6,34 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+
+	"google.golang.org/protobuf/types/descriptorpb"
 )
 
 var (
 	// INDENT Use 2 spaces
 	INDENT               = "  "
-	curIndent            string
 	defaultStructureName = "Default"
 )
 
-// GenProto genenate proto file content
-func (pr *ProtoSheet) GenProto() {
-	pr.AddPreHead()
-
-	// Head
-	pr.AddMessageHead(pr.Name)
-
-	// Vars
-	for _, val := range pr.vars {
-		pr.AddVal(val)
+// GenProto genenate proto file content from descriptor of current sheet
+func (pr *ProtoSheet) GenProto() error {
+	if err := pr.BuildDescriptor(); err != nil {
+		return err
 	}
 
-	// Optional Struct
-	for _, optS := range pr.optStructs {
-		pr.AddOptionalStruct(optS)
-	}
+	pr.outProto = nil
+	pr.comments = sourceComments(pr.fileDesc)
 
-	// Repeats
-	for _, repeat := range pr.repeats {
-		pr.AddRepeat(repeat)
-	}
+	pr.AddPreHead()
 
-	pr.AddMessageTail()
+	for i, msg := range pr.fileDesc.MessageType {
+		pr.AddMessage(msg, []string{msg.GetName()}, []int32{fileMessageTypeTag, int32(i)})
+	}
 
-	// MessageArray
-	pr.AddMessageArray()
+	return nil
 }
 
 // Hash generate hash of proto content
@@ -56,7 +48,7 @@ func (pr *ProtoSheet) ProtoHash() {
 // IncreaseIndent increase indent
 func (pr *ProtoSheet) IncreaseIndent() {
 	pr.tabCount++
-	curIndent = strings.Repeat(INDENT, pr.tabCount)
+	pr.curIndent = strings.Repeat(INDENT, pr.tabCount)
 }
 
 // DecreaseIndent decrease indent
@@ -64,22 +56,21 @@ func (pr *ProtoSheet) DecreaseIndent() {
 	if pr.tabCount > 0 {
 		pr.tabCount--
 	}
-	curIndent = strings.Repeat(INDENT, pr.tabCount)
+	pr.curIndent = strings.Repeat(INDENT, pr.tabCount)
 }
 
 // AddOneEmptyLine add an empty line
 func (pr *ProtoSheet) AddOneEmptyLine() {
-	pr.outProto = append(pr.outProto, fmt.Sprintf("%s", curIndent))
+	pr.outProto = append(pr.outProto, fmt.Sprintf("%s", pr.curIndent))
 }
 
 // AddPreHead add syntax and package info
 func (pr *ProtoSheet) AddPreHead() {
-	ver := 2
-	if pr.isProto3 {
-		ver = 3
+	pr.outProto = append(pr.outProto, fmt.Sprintf("syntax = \"%s\";", pr.fileDesc.GetSyntax()))
+	pr.outProto = append(pr.outProto, fmt.Sprintf("package %s;", pr.fileDesc.GetPackage()))
+	if goPackage := pr.fileDesc.GetOptions().GetGoPackage(); goPackage != "" {
+		pr.outProto = append(pr.outProto, fmt.Sprintf("option go_package = %s;", strconv.Quote(goPackage)))
 	}
-	pr.outProto = append(pr.outProto, fmt.Sprintf("syntax = \"proto%d\";", ver))
-	pr.outProto = append(pr.outProto, fmt.Sprintf("package %s;", cfg.PackageName))
 	pr.AddOneEmptyLine()
 }
 
@@ -88,142 +79,231 @@ func (pr *ProtoSheet) AddMessageHead(name string) {
 	if name == "" {
 		return
 	}
-	pr.outProto = append(pr.outProto, fmt.Sprintf("%smessage %s {", curIndent, name))
+	pr.outProto = append(pr.outProto, fmt.Sprintf("%smessage %s {", pr.curIndent, name))
 	pr.IncreaseIndent()
 }
 
-// AddOneDefine add a proto defination
-func (pr *ProtoSheet) AddOneDefine(isRepeat bool, comment, p2type, typ, name, defaultValStr string, idx int) {
-	// default value
-	defaultStr := fmt.Sprintf(" [default = %v]", defaultValStr)
-	if defaultValStr == "" {
-		defaultStr = ""
-	}
+// AddMessage add a message define, nested message is added right before the first field using it
+func (pr *ProtoSheet) AddMessage(msg *descriptorpb.DescriptorProto, scope []string, path []int32) {
+	pr.AddMessageHead(msg.GetName())
 
-	// comment
-	if comment != "" {
-		pr.outProto = append(pr.outProto, fmt.Sprintf("%s/* %s */", curIndent, comment)) // comment
+	// Enums
+	for i, e := range msg.EnumType {
+		pr.AddEnum(e, appendPath(path, msgEnumTypeTag, i))
 	}
 
-	// name
-	if name == "" {
-		name = title2Lowercase(strings.TrimSpace(typ)) // struct use type name as name
-		if name == "" {                                // if name is still missing, use a default name
-			name = defaultStructureName
+	pr.AddReserved(msg)
+
+	added := make(map[string]struct{}, len(msg.NestedType))
+	entries := make(map[string]*descriptorpb.DescriptorProto) // map entries are not output as messages
+	for _, nested := range msg.NestedType {
+		if nested.GetOptions().GetMapEntry() {
+			entries[pr.fullName(append(scope, nested.GetName())...)] = nested
 		}
 	}
 
-	// type
-	if typ == "float32" { // convert go varient name to proto varient name
-		typ = "float"
-	}
-	if typ == "float64" { // convert go varient name to proto varient name
-		typ = "double"
+	for i, field := range msg.Field {
+		isMessage := field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+		if i > 0 && (isMessage || field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED) {
+			pr.AddOneEmptyLine()
+		}
+
+		if entry, ok := entries[field.GetTypeName()]; ok {
+			pr.AddMapField(field, entry, scope, appendPath(path, msgFieldTag, i))
+			continue
+		}
+
+		if isMessage {
+			for j, nested := range msg.NestedType {
+				if _, ok := added[nested.GetName()]; ok || pr.fullName(append(scope, nested.GetName())...) != field.GetTypeName() {
+					continue
+				}
+				added[nested.GetName()] = struct{}{}
+				pr.AddMessage(nested, append(scope, nested.GetName()), appendPath(path, msgNestedTypeTag, j))
+			}
+		}
+
+		pr.AddField(field, scope, appendPath(path, msgFieldTag, i))
 	}
-	if !pr.isProto3 && p2type != "" { // only for proto2
-		if p2type == Unique {
-			p2type = Opt
+
+	pr.AddMessageTail()
+}
+
+// AddReserved add reserved numbers and names of removed fields
+func (pr *ProtoSheet) AddReserved(msg *descriptorpb.DescriptorProto) {
+	var ranges []string
+	for _, r := range msg.ReservedRange {
+		if r.GetEnd()-r.GetStart() == 1 {
+			ranges = append(ranges, fmt.Sprint(r.GetStart()))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d to %d", r.GetStart(), r.GetEnd()-1)) // end is exclusive in descriptor
 		}
-		typ = fmt.Sprintf("%s %s", p2type, typ)
 	}
-	if isRepeat {
-		typ = "repeated " + typ
+	if len(ranges) > 0 {
+		pr.outProto = append(pr.outProto, fmt.Sprintf("%sreserved %s;", pr.curIndent, strings.Join(ranges, ", ")))
 	}
 
-	// generate line
-	pr.outProto = append(pr.outProto, fmt.Sprintf("%s%s %s = %d%v;", curIndent, typ, name, idx, defaultStr)) // define
+	var names []string
+	for _, name := range msg.ReservedName {
+		names = append(names, strconv.Quote(name))
+	}
+	if len(names) > 0 {
+		pr.outProto = append(pr.outProto, fmt.Sprintf("%sreserved %s;", pr.curIndent, strings.Join(names, ", ")))
+	}
 }
 
-// AddMessageTail add a proto message tail
-func (pr *ProtoSheet) AddMessageTail() {
-	pr.DecreaseIndent()
-	pr.outProto = append(pr.outProto, fmt.Sprintf("%s}", curIndent))
-	pr.AddOneEmptyLine()
-}
+// AddEnum add an enum define
+func (pr *ProtoSheet) AddEnum(e *descriptorpb.EnumDescriptorProto, path []int32) {
+	pr.outProto = append(pr.outProto, fmt.Sprintf("%senum %s {", pr.curIndent, e.GetName()))
+	pr.IncreaseIndent()
+
+	for i, v := range e.Value {
+		pr.AddComment(appendPath(path, enumValueTag, i))
+		pr.outProto = append(pr.outProto, fmt.Sprintf("%s%s = %d;", pr.curIndent, v.GetName(), v.GetNumber()))
+	}
 
-// AddVal add a proto variable define
-func (pr *ProtoSheet) AddVal(sh *Val) {
-	pr.AddOneDefine(false, sh.comment, sh.proto2Type, sh.typ, sh.name, sh.defaultValueStr, sh.fieldNum)
+	pr.AddMessageTail()
 }
 
-func (pr *ProtoSheet) AddOptionalStruct(optS *OptStruct) {
-	pr.AddOneEmptyLine()
+// AddField add a proto field define
+func (pr *ProtoSheet) AddField(field *descriptorpb.FieldDescriptorProto, scope []string, path []int32) {
+	pr.AddComment(path)
 
-	pr.AddMessageHead(optS.name)
+	// type
+	typ := pr.typeName(field, scope)
 
-	for _, val := range optS.fields {
-		pr.AddVal(val)
+	// label, proto3 has no required and optional is implicit
+	switch label := field.GetLabel(); {
+	case label == descriptorpb.FieldDescriptorProto_LABEL_REPEATED:
+		typ = "repeated " + typ
+	case !pr.isProto3:
+		typ = fmt.Sprintf("%s %s", strings.TrimPrefix(strings.ToLower(label.String()), "label_"), typ)
 	}
 
-	pr.AddMessageTail()
+	// default value
+	var defaultStr string
+	if field.DefaultValue != nil {
+		defaultValue := field.GetDefaultValue()
+		if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_STRING {
+			defaultValue = strconv.Quote(defaultValue)
+		}
+		defaultStr = fmt.Sprintf(" [default = %v]", defaultValue)
+	}
 
-	pr.AddOptStructTail(optS)
+	// generate line
+	pr.outProto = append(pr.outProto, fmt.Sprintf("%s%s %s = %d%v;", pr.curIndent, typ, field.GetName(), field.GetNumber(), defaultStr)) // define
 }
 
-// AddRepeat add repeat struct
-func (pr *ProtoSheet) AddRepeat(repeat *Repeat) {
-	pr.AddOneEmptyLine()
+// AddMapField add a map field define, e.g. "map<string, int32> Weights = 3;"
+func (pr *ProtoSheet) AddMapField(field *descriptorpb.FieldDescriptorProto, entry *descriptorpb.DescriptorProto, scope []string, path []int32) {
+	pr.AddComment(path)
 
-	if repeat.opts != nil {
-		pr.AddMessageHead(repeat.opts.name)
+	// entry is nested in current message, so its fields use the same scope
+	typ := fmt.Sprintf("map<%s, %s>", pr.typeName(entry.Field[0], scope), pr.typeName(entry.Field[1], scope))
+	pr.outProto = append(pr.outProto, fmt.Sprintf("%s%s %s = %d;", pr.curIndent, typ, field.GetName(), field.GetNumber()))
+}
 
-		for _, val := range repeat.opts.fields {
-			pr.AddVal(val)
-		}
+// typeName returns type of a field in proto file
+func (pr *ProtoSheet) typeName(field *descriptorpb.FieldDescriptorProto, scope []string) string {
+	if field.TypeName != nil {
+		return pr.relativeName(field.GetTypeName(), scope)
+	}
 
-		pr.AddMessageTail()
+	return strings.TrimPrefix(strings.ToLower(field.GetType().String()), "type_")
+}
+
+// AddComment add leading comment of a define if exists
+func (pr *ProtoSheet) AddComment(path []int32) {
+	if comment, ok := pr.comments[pathKey(path)]; ok {
+		pr.outProto = append(pr.outProto, fmt.Sprintf("%s/* %s */", pr.curIndent, comment))
 	}
+}
 
-	// Add repeat tail
-	pr.AddRepeatTail(repeat)
+// AddMessageTail add a proto message tail
+func (pr *ProtoSheet) AddMessageTail() {
+	pr.DecreaseIndent()
+	pr.outProto = append(pr.outProto, fmt.Sprintf("%s}", pr.curIndent))
+	pr.AddOneEmptyLine()
 }
 
-// AddRepeatTail add repeat declare
-func (pr *ProtoSheet) AddRepeatTail(repeat *Repeat) {
-	if repeat.val != nil {
-		pr.AddOneDefine(true, repeat.val.comment, "", repeat.val.typ, repeat.val.name, "", repeat.fieldNum)
-		return
+// relativeName returns the shortest type name which can be resolved in scope
+func (pr *ProtoSheet) relativeName(typeName string, scope []string) string {
+	name := strings.TrimPrefix(strings.TrimPrefix(typeName, "."), pr.cfg.PackageName+".")
+
+	for i := len(scope); i > 0; i-- {
+		prefix := strings.Join(scope[:i], ".") + "."
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
 	}
 
-	pr.AddOneDefine(true, "", "", repeat.name, repeat.comment, "", repeat.fieldNum)
+	return name
 }
 
-func (pr *ProtoSheet) AddOptStructTail(opts *OptStruct) {
-	pr.AddOneDefine(false, "", "optional", opts.name, opts.comment, "", opts.fieldNum)
+// sourceComments returns map[path]comment from source code info
+func sourceComments(fd *descriptorpb.FileDescriptorProto) map[string]string {
+	comments := make(map[string]string)
+	for _, loc := range fd.GetSourceCodeInfo().GetLocation() {
+		if loc.LeadingComments != nil {
+			comments[pathKey(loc.Path)] = loc.GetLeadingComments()
+		}
+	}
+
+	return comments
 }
 
-// AddMessageArray add an array for current message as XXX_ARRAY
-func (pr *ProtoSheet) AddMessageArray() {
-	pr.AddMessageHead(pr.Name + "_ARRAY")
-	pr.outProto = append(pr.outProto, fmt.Sprintf("%srepeated %s items = 1;", curIndent, pr.Name))
-	pr.AddMessageTail()
+func pathKey(path []int32) string {
+	return fmt.Sprint(path)
 }
 
 // WriteProto output proto file to "./proto/sheetname.proto"
-func (pr *ProtoSheet) WriteProto() {
-	f, err := os.Create(filepath.Join(cfg.ProtoOutPath, strings.ToLower(pr.Name)+cfg.ProtoOutExt))
-	defer f.Close()
-
+func (pr *ProtoSheet) WriteProto() error {
+	f, err := os.Create(filepath.Join(pr.cfg.ProtoOutPath, strings.ToLower(pr.Name)+pr.cfg.ProtoOutExt))
 	if err != nil {
-		panic(err)
+		return err
 	}
+	defer f.Close()
 
 	w := bufio.NewWriter(f)
 	for _, line := range pr.outProto {
 		_, err := w.WriteString(line + "\n")
 		if err != nil {
-			panic(err)
+			return err
 		}
 	}
 
 	err = w.Flush()
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	if err := f.Close(); err != nil {
-		fmt.Println(err.Error())
+	return f.Close()
+}
+
+// protoFieldName returns field name used in proto define
+func protoFieldName(name, typ string) string {
+	if name == "" {
+		name = title2Lowercase(strings.TrimSpace(typ)) // struct use type name as name
+		if name == "" {                                // if name is still missing, use a default name
+			name = defaultStructureName
+		}
+	}
+
+	return name
+}
+
+// fieldName returns name of the optional struct field in sheet message
+func (optS *OptStruct) fieldName() string {
+	return protoFieldName(optS.comment, optS.name)
+}
+
+// fieldName returns name of the repeat field in sheet message
+func (repeat *Repeat) fieldName() string {
+	if repeat.opts == nil || repeat.inline {
+		return repeat.name
 	}
+
+	return protoFieldName(repeat.comment, repeat.name)
 }
 
 func title2Lowercase(title string) string {
@@ -2,13 +2,14 @@ package lib
 
 import (
 	"encoding/hex"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func genTestProtoRow() *ProtoSheet {
-	pr := newProtoRow()
+	pr := newProtoRow(newTestConfig())
 
 	pr.Name = "TestProtoRow"
 
@@ -44,7 +45,7 @@ func genTestProtoRow() *ProtoSheet {
 	sh2 := &Val{
 		CommonInfo: CommonInfo{
 			colIdx:   1,
-			fieldNum: 1,
+			fieldNum: 2,
 			name:     "TestRepeat1",
 			comment:  "** This is TestRepeat1 **",
 		},
@@ -58,151 +59,107 @@ func genTestProtoRow() *ProtoSheet {
 		CommonInfo: CommonInfo{
 			colIdx:   2,
 			fieldNum: 2,
-			name:     "TestRepeatStruct",
-			comment:  "TestRepeatStructData",
+			name:     "TestRepeat1",
 		},
 		repeatIdx: 1,
+		val:       sh2,
 	}
 
-	rp2 := new(Repeat)
-	*rp2 = *rp
-
-	rp.val = sh2
-
-	rp2.opts = optS
-	rp2.val = nil
+	rp2 := &Repeat{
+		CommonInfo: CommonInfo{
+			colIdx:   4,
+			fieldNum: 4,
+			name:     "TestOptStruct",
+			comment:  "TestRepeatStructData",
+		},
+		repeatIdx: 1,
+		opts:      optS,
+	}
 
 	pr.repeats = append(pr.repeats, rp, rp2)
 
 	return pr
 }
 
-/*func TestProtoSheet_GenProto(t *testing.T) {
+func TestGenProtoProto2(t *testing.T) {
 	pr := genTestProtoRow()
-	pr.isProto3 = true
-
-	pr.GenProto()
-
-	for _, line := range pr.outProto {
-		fmt.Println(line)
-	}
-}*/
-
-func TestAddMessageProto2(t *testing.T) {
-	pr := genTestProtoRow()
-	var idx int
-
-	checkOutput := func(out string) {
-		assert.Equal(t, out, pr.outProto[idx])
-		idx++
-	}
-
-	pr.AddPreHead()
-	checkOutput("syntax = \"proto2\";")
-	checkOutput("package ProtobufGen;")
-	checkOutput("")
-
-	pr.AddMessageHead(pr.Name)
-	checkOutput("message TestProtoRow {")
-
-	pr.AddVal(pr.vars[0])
-	checkOutput("  /* * This is TestFiled1 * */")
-	checkOutput("  optional string TestField1 = 1 [default = \"default\"];")
-
-	pr.AddOptionalStruct(pr.optStructs[0])
-	checkOutput("  ")
-	checkOutput("  message TestOptStruct {")
-	checkOutput("    /* * This is TestFiled1 * */")
-	checkOutput("    optional string TestField1 = 1 [default = \"default\"];")
-	checkOutput("  }")
-	checkOutput("  ")
-	checkOutput("  optional TestOptStruct TestOptStructData = 3;")
-
-	pr.AddRepeat(pr.repeats[0])
-	checkOutput("  ")
-	checkOutput("  /* ** This is TestRepeat1 ** */")
-	checkOutput("  repeated int64 TestRepeat1 = 2;")
-
-	pr.AddRepeat(pr.repeats[1])
-	checkOutput("  ")
-	checkOutput("  message TestOptStruct {")
-	checkOutput("    /* * This is TestFiled1 * */")
-	checkOutput("    optional string TestField1 = 1 [default = \"default\"];")
-	checkOutput("  }")
-	checkOutput("  ")
-	checkOutput("  repeated TestRepeatStruct TestRepeatStructData = 2;")
-
-	pr.AddMessageTail()
-	checkOutput("}")
-	checkOutput("")
-
-	pr.AddMessageArray()
-	checkOutput("message TestProtoRow_ARRAY {")
-	checkOutput("  repeated TestProtoRow items = 1;")
-	checkOutput("}")
-	checkOutput("")
+	assert.NoError(t, pr.GenProto())
+
+	assert.Equal(t, []string{
+		"syntax = \"proto2\";",
+		"package ProtobufGen;",
+		"",
+		"message TestProtoRow {",
+		"  /* * This is TestFiled1 * */",
+		"  optional string TestField1 = 1 [default = \"default\"];",
+		"  ",
+		"  message TestOptStruct {",
+		"    /* * This is TestFiled1 * */",
+		"    optional string TestField1 = 1 [default = \"default\"];",
+		"  }",
+		"  ",
+		"  optional TestOptStruct TestOptStructData = 3;",
+		"  ",
+		"  /* ** This is TestRepeat1 ** */",
+		"  repeated int64 TestRepeat1 = 2;",
+		"  ",
+		"  repeated TestOptStruct TestRepeatStructData = 4;",
+		"}",
+		"",
+		"message TestProtoRow_ARRAY {",
+		"  repeated TestProtoRow items = 1;",
+		"}",
+		"",
+	}, pr.outProto)
 }
 
-func TestAddMessageProto3(t *testing.T) {
+func TestGenProtoProto3(t *testing.T) {
 	pr := genTestProtoRow()
 	pr.isProto3 = true
-	var idx int
+	assert.NoError(t, pr.GenProto())
+
+	assert.Equal(t, []string{
+		"syntax = \"proto3\";",
+		"package ProtobufGen;",
+		"",
+		"message TestProtoRow {",
+		"  /* * This is TestFiled1 * */",
+		"  string TestField1 = 1;",
+		"  ",
+		"  message TestOptStruct {",
+		"    /* * This is TestFiled1 * */",
+		"    string TestField1 = 1;",
+		"  }",
+		"  ",
+		"  TestOptStruct TestOptStructData = 3;",
+		"  ",
+		"  /* ** This is TestRepeat1 ** */",
+		"  repeated int64 TestRepeat1 = 2;",
+		"  ",
+		"  repeated TestOptStruct TestRepeatStructData = 4;",
+		"}",
+		"",
+		"message TestProtoRow_ARRAY {",
+		"  repeated TestProtoRow items = 1;",
+		"}",
+		"",
+	}, pr.outProto)
+}
 
-	checkOutput := func(out string) {
-		assert.Equal(t, out, pr.outProto[idx])
-		idx++
-	}
+func TestGenProtoInvalidType(t *testing.T) {
+	pr := genTestProtoRow()
+	pr.vars[0].typ = "int128"
 
-	pr.AddPreHead()
-	checkOutput("syntax = \"proto3\";")
-	checkOutput("package ProtobufGen;")
-	checkOutput("")
-
-	pr.AddMessageHead(pr.Name)
-	checkOutput("message TestProtoRow {")
-
-	pr.AddVal(pr.vars[0])
-	checkOutput("  /* * This is TestFiled1 * */")
-	checkOutput("  string TestField1 = 1 [default = \"default\"];")
-
-	pr.AddOptionalStruct(pr.optStructs[0])
-	checkOutput("  ")
-	checkOutput("  message TestOptStruct {")
-	checkOutput("    /* * This is TestFiled1 * */")
-	checkOutput("    string TestField1 = 1 [default = \"default\"];")
-	checkOutput("  }")
-	checkOutput("  ")
-	checkOutput("  TestOptStruct TestOptStructData = 3;")
-
-	pr.AddRepeat(pr.repeats[0])
-	checkOutput("  ")
-	checkOutput("  /* ** This is TestRepeat1 ** */")
-	checkOutput("  repeated int64 TestRepeat1 = 2;")
-
-	pr.AddRepeat(pr.repeats[1])
-	checkOutput("  ")
-	checkOutput("  message TestOptStruct {")
-	checkOutput("    /* * This is TestFiled1 * */")
-	checkOutput("    string TestField1 = 1 [default = \"default\"];")
-	checkOutput("  }")
-	checkOutput("  ")
-	checkOutput("  repeated TestRepeatStruct TestRepeatStructData = 2;")
-
-	pr.AddMessageTail()
-	checkOutput("}")
-	checkOutput("")
-
-	pr.AddMessageArray()
-	checkOutput("message TestProtoRow_ARRAY {")
-	checkOutput("  repeated TestProtoRow items = 1;")
-	checkOutput("}")
-	checkOutput("")
+	err := pr.GenProto()
+	var se *SheetError
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, "TestField1", se.Field)
 }
 
 func TestHash(t *testing.T) {
 	pr := genTestProtoRow()
-	pr.GenProto()
+	assert.NoError(t, pr.GenProto())
 	pr.ProtoHash()
 
-	assert.Equal(t, "a00323db48cda8b383f8d3f3c284e37c", hex.EncodeToString(pr.protoHash[:]))
+	assert.Equal(t, "39bc6491fc47e30482147dd7e6f349be", hex.EncodeToString(pr.protoHash[:]))
 }
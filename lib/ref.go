@@ -0,0 +1,180 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// RefPrefix is the type annotation for references, e.g. "uint32 ref:ITEM.ItemID"
+const RefPrefix = "ref:"
+
+var (
+	ErrRefInvalid  = errors.New("invalid reference")
+	ErrDanglingRef = errors.New("dangling reference")
+)
+
+// Ref points to a unique column in another sheet
+type Ref struct {
+	Sheet string
+	Field string
+}
+
+// RefCell is a cell refers to a unique value in another sheet
+type RefCell struct {
+	Field    string `json:"field"`
+	Row      int    `json:"row"`
+	Col      int    `json:"col"`
+	Value    string `json:"value"`
+	TarSheet string `json:"tar_sheet"`
+	TarField string `json:"tar_field"`
+}
+
+// RefInfo contains unique values and references of a sheet
+// It is saved in cache, so references can be validated without reading unchanged sheets
+type RefInfo struct {
+	File string              `json:"file"`
+	Keys map[string][]string `json:"keys"` // map[uniqueFieldName]values
+	Refs []*RefCell          `json:"refs"`
+}
+
+// parseRef parse annotation like "ref:ITEM.ItemID"
+func parseRef(annotation string) (*Ref, error) {
+	parts := strings.Split(strings.TrimPrefix(annotation, RefPrefix), ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("%w %s, should be %sSHEET.Field", ErrRefInvalid, annotation, RefPrefix)
+	}
+
+	return &Ref{Sheet: parts[0], Field: parts[1]}, nil
+}
+
+// splitType split type cell into type and annotations, e.g. "uint32 ref:ITEM.ItemID"
+// spaces in inline types are removed, e.g. "struct{id: uint32, count: uint32}[]"
+func splitType(typeCell string) (string, []string) {
+	var parts []string
+	var sb strings.Builder
+	depth := 0
+
+	for _, r := range typeCell {
+		switch {
+		case r == '<' || r == '{':
+			depth++
+		case r == '>' || r == '}':
+			depth--
+		case unicode.IsSpace(r):
+			if depth <= 0 && sb.Len() > 0 {
+				parts = append(parts, sb.String())
+				sb.Reset()
+			}
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	if sb.Len() > 0 {
+		parts = append(parts, sb.String())
+	}
+
+	if len(parts) == 0 {
+		return "", nil
+	}
+
+	return parts[0], parts[1:]
+}
+
+// addRef record a non-empty cell which refers to another sheet
+func (pr *ProtoSheet) addRef(val *Val, colIdx int, value string) {
+	value = strings.TrimSpace(value)
+	if val.ref == nil || value == "" {
+		return
+	}
+
+	pr.refs = append(pr.refs, &RefCell{
+		Field:    val.name,
+		Col:      colIdx + 1,
+		Value:    value,
+		TarSheet: val.ref.Sheet,
+		TarField: val.ref.Field,
+	})
+}
+
+// refInfo collect unique values and references after all data are read
+func (pr *ProtoSheet) refInfo(fileName string) *RefInfo {
+	info := &RefInfo{
+		File: fileName,
+		Keys: make(map[string][]string, len(pr.uniqueMap)),
+		Refs: pr.refs,
+	}
+
+	for field, values := range pr.uniqueMap {
+		keys := make([]string, 0, len(values))
+		for value := range values {
+			keys = append(keys, strings.TrimSpace(value))
+		}
+		sort.Strings(keys)
+		info.Keys[field] = keys
+	}
+
+	return info
+}
+
+// updateRefInfo save ref info of a sheet read in current run
+func (cv *Converter) updateRefInfo(sheetName string, info *RefInfo) {
+	cv.mutex.Lock()
+	defer cv.mutex.Unlock()
+
+	cv.refInfos[sheetName] = info
+}
+
+// checkRefs validate all references after all sheets are read
+// sheets not read in current run use ref info from cache
+func (cv *Converter) checkRefs(report *Report) {
+	keys := make(map[string]map[string]map[string]struct{}) // map[sheet][field][value]
+	for sheetName, info := range cv.refInfos {
+		keys[sheetName] = make(map[string]map[string]struct{}, len(info.Keys))
+		for field, values := range info.Keys {
+			keys[sheetName][field] = make(map[string]struct{}, len(values))
+			for _, value := range values {
+				keys[sheetName][field][value] = struct{}{}
+			}
+		}
+	}
+
+	for sheetName, info := range cv.refInfos {
+		for _, ref := range info.Refs {
+			se := &SheetError{File: info.File, Sheet: sheetName, Row: ref.Row, Col: ref.Col, Field: ref.Field}
+
+			tarSheet, ok := keys[ref.TarSheet]
+			if !ok {
+				se.Err = fmt.Errorf("%w, sheet %s not found", ErrRefInvalid, ref.TarSheet)
+				report.addError(se)
+				continue
+			}
+			tarField, ok := tarSheet[ref.TarField]
+			if !ok {
+				se.Err = fmt.Errorf("%w, %s.%s is not a unique field", ErrRefInvalid, ref.TarSheet, ref.TarField)
+				report.addError(se)
+				continue
+			}
+			if _, ok := tarField[ref.Value]; !ok {
+				se.Err = fmt.Errorf("%w, %s not found in %s.%s", ErrDanglingRef, ref.Value, ref.TarSheet, ref.TarField)
+				report.addError(se)
+			}
+		}
+	}
+}
+
+// loadRefInfos read ref infos from cache, and drop sheets no longer in config
+func (cv *Converter) loadRefInfos() {
+	cv.refInfos = make(map[string]*RefInfo)
+	if cv.cacher == nil {
+		return
+	}
+
+	for sheetName, info := range cv.cacher.RefInfos {
+		if _, ok := cv.sheetFileMap[info.File]; ok {
+			cv.refInfos[sheetName] = info
+		}
+	}
+}
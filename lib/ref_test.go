@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRef(t *testing.T) {
+	ref, err := parseRef("ref:ITEM.ItemID")
+	assert.NoError(t, err)
+	assert.Equal(t, &Ref{Sheet: "ITEM", Field: "ItemID"}, ref)
+
+	for _, in := range []string{"ref:ITEM", "ref:.ItemID", "ref:ITEM.", "ref:A.B.C"} {
+		_, err := parseRef(in)
+		assert.True(t, errors.Is(err, ErrRefInvalid), in)
+	}
+}
+
+func TestCheckRefs(t *testing.T) {
+	cv := newConverter(newTestConfig())
+
+	item := newProtoRow(cv.cfg)
+	assert.NoError(t, item.updateHeads(newTestSheet("ITEM", [][]string{
+		{"unique", "optional"},
+		{"uint32", "string"},
+		{"ItemID", "Name"},
+		{"", ""},
+		{"1001", "Sword"},
+		{"1002", "Shield"},
+	})))
+	assert.NoError(t, item.readData(newTestSheet("ITEM", [][]string{
+		{}, {}, {}, {},
+		{"1001", "Sword"},
+		{"1002", "Shield"},
+	})))
+	cv.updateRefInfo(item.Name, item.refInfo("Item.xlsx"))
+
+	reward := newProtoRow(cv.cfg)
+	sheet := newTestSheet("REWARD", [][]string{
+		{"required", "repeated", "optional", "optional"},
+		{"uint32", "2", "uint32 ref:ITEM.ItemID", "uint32 ref:ITEM.ItemID"},
+		{"RewardID", "", "ItemID", "ItemID"},
+		{"", "", "", ""},
+		{"1", "2", "1001", "1002"},
+		{"2", "2", "1003", ""},
+		{"3", "1", "1002", "1004"}, // not counted
+	})
+	assert.NoError(t, reward.updateHeads(sheet))
+	assert.NoError(t, reward.readData(sheet))
+	cv.updateRefInfo(reward.Name, reward.refInfo("Reward.xlsx"))
+
+	report := new(Report)
+	cv.checkRefs(report)
+	if assert.Equal(t, 1, len(report.Errors)) {
+		se := report.Errors[0]
+		assert.Equal(t, "Reward.xlsx", se.File)
+		assert.Equal(t, "REWARD", se.Sheet)
+		assert.Equal(t, 6, se.Row)
+		assert.Equal(t, 3, se.Col)
+		assert.True(t, errors.Is(se, ErrDanglingRef))
+	}
+
+	// target sheet is missing
+	delete(cv.refInfos, "ITEM")
+	report = new(Report)
+	cv.checkRefs(report)
+	assert.Equal(t, 4, len(report.Errors))
+	assert.True(t, errors.Is(report.Errors[0], ErrRefInvalid))
+}
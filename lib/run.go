@@ -1,63 +1,340 @@
 package lib
 
 import (
+	"errors"
 	"fmt"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
+
+	"github.com/tealeg/xlsx"
 )
 
+// Options controls how Run handles xlsx files
+type Options struct {
+	UseCache     bool // skip xlsx files which are not changed since last run
+	UseGoroutine bool // handle sheets concurrently
+	Workers      int  // count of sheets handled at the same time, the count of CPUs if not positive
+	FailFast     bool // stop at the first failed sheet instead of collecting all errors
+	StrictCompat bool // fail sheets whose new proto breaks clients using the old one
+	DryRun       bool // read and check sheets without writing any file, cache or field lock
+}
+
+// Report contains the result of a run
+type Report struct {
+	Sheets  []string       // sheets converted successfully
+	Errors  []*SheetError  // errors of failed sheets
+	Skipped map[string]int // map[sheetName]count of disabled rows
+
+	mutex sync.Mutex
+}
+
+// SheetError contains the position where an error happened while handling a sheet
+// Row and Col start from 1, 0 means unknown
+type SheetError struct {
+	File  string
+	Sheet string
+	Row   int
+	Col   int
+	Field string
+	Err   error
+}
+
+// Error implements error interface
+func (e *SheetError) Error() string {
+	var parts []string
+	if e.File != "" {
+		parts = append(parts, e.File)
+	}
+	if e.Sheet != "" {
+		parts = append(parts, "sheet "+e.Sheet)
+	}
+	if e.Row > 0 {
+		parts = append(parts, fmt.Sprintf("row %d", e.Row))
+	}
+	if e.Col > 0 {
+		parts = append(parts, "col "+xlsx.ColIndexToLetters(e.Col-1))
+	}
+	if e.Field != "" {
+		parts = append(parts, "field "+e.Field)
+	}
+
+	if len(parts) == 0 {
+		return e.Err.Error()
+	}
+
+	return fmt.Sprintf("%s: %v", strings.Join(parts, ", "), e.Err)
+}
+
+// Unwrap returns the cause of the error
+func (e *SheetError) Unwrap() error {
+	return e.Err
+}
+
+// toSheetError fills file and sheet name into err, wrapping it if necessary
+func toSheetError(fileName, sheetName string, err error) *SheetError {
+	var se *SheetError
+	if !errors.As(err, &se) {
+		se = &SheetError{Err: err}
+	}
+	if se.File == "" {
+		se.File = fileName
+	}
+	if se.Sheet == "" {
+		se.Sheet = sheetName
+	}
+
+	return se
+}
+
+func (r *Report) addSheet(sheetName string, skipped int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Sheets = append(r.Sheets, sheetName)
+	if skipped > 0 {
+		if r.Skipped == nil {
+			r.Skipped = make(map[string]int)
+		}
+		r.Skipped[sheetName] = skipped
+	}
+}
+
+func (r *Report) addError(err *SheetError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Errors = append(r.Errors, err)
+}
+
+// HasErrors reports whether any sheet failed
+func (r *Report) HasErrors() bool {
+	return len(r.Errors) > 0
+}
+
+// Summary returns a readable summary of the report
+func (r *Report) Summary() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%d sheet(s) converted, %d failed\n", len(r.Sheets), len(r.Errors))
+	for _, err := range r.Errors {
+		fmt.Fprintf(&sb, "  %v\n", err)
+	}
+	for _, sheet := range r.Sheets {
+		if n := r.Skipped[sheet]; n > 0 {
+			fmt.Fprintf(&sb, "  %s: %d row(s) skipped\n", sheet, n)
+		}
+	}
+
+	return sb.String()
+}
+
+// sort keeps report output stable no matter which goroutine finished first
+func (r *Report) sort() {
+	sort.Strings(r.Sheets)
+	sort.SliceStable(r.Errors, func(i, j int) bool {
+		if r.Errors[i].File != r.Errors[j].File {
+			return r.Errors[i].File < r.Errors[j].File
+		}
+		if r.Errors[i].Sheet != r.Errors[j].Sheet {
+			return r.Errors[i].Sheet < r.Errors[j].Sheet
+		}
+		return r.Errors[i].Row < r.Errors[j].Row
+	})
+}
+
 // Run execute the xlsx2pb and output proto files and binary data files
-func Run(isCacheOn, isUseGoroutine bool) {
-	if isCacheOn {
+// An error is returned if any sheet failed, details can be found in report
+func (cv *Converter) Run(opts Options) (*Report, error) {
+	report := new(Report)
+	cv.strictCompat = opts.StrictCompat
+	cv.dryRun = opts.DryRun
+
+	cv.cacher = nil
+	if opts.UseCache {
 		fmt.Println("cache on, init cache...")
-		CacheInit()
+		if err := cv.CacheInit(); err != nil {
+			return report, err
+		}
 	}
 
-	if isUseGoroutine {
-		runByGoroutine()
-	} else {
-		runOneByOne()
+	if err := cv.loadFieldLock(); err != nil {
+		return report, fmt.Errorf("load field lock fail, %v", err)
 	}
 
-	if isCacheOn {
+	cv.loadRefInfos()
+	cv.enumsChanged = cv.loadEnums(report)
+
+	// enums are used by other sheets, do not continue if any of them failed
+	if !opts.FailFast || !report.HasErrors() {
+		jobs := cv.outdatedJobs(report)
+		cv.sources = newSourceCache()
+		for _, job := range jobs {
+			cv.sources.acquire(cv.sourcePaths(job.filename))
+		}
+
+		if opts.UseGoroutine {
+			cv.runByWorkers(jobs, opts, report)
+		} else {
+			cv.runOneByOne(jobs, opts, report)
+		}
+		cv.sources = nil
+	}
+
+	// references are validated after all sheets are read
+	if !opts.FailFast || !report.HasErrors() {
+		cv.checkRefs(report)
+	}
+	report.sort()
+
+	if opts.DryRun {
+		return report, report.err(opts)
+	}
+
+	// locks of converted sheets are saved even if other sheets failed
+	if err := cv.saveFieldLock(); err != nil {
+		return report, err
+	}
+
+	if opts.UseCache {
 		fmt.Println("saving cache ...")
-		if err := cacher.Save(); err != nil {
-			fmt.Println(err)
+		if err := cv.SaveCache(); err != nil {
+			return report, err
 		}
 	}
+
+	return report, report.err(opts)
 }
 
-func runOneByOne() {
-	for filename, sheets := range sheetFileMap {
-		if IsXlsxChanged(filename) {
+// err returns the first error in fail fast mode, or the count of failed sheets
+func (r *Report) err(opts Options) error {
+	if !r.HasErrors() {
+		return nil
+	}
+	if opts.FailFast {
+		return r.Errors[0]
+	}
+
+	return fmt.Errorf("%d sheet(s) failed", len(r.Errors))
+}
+
+// runSheet read one sheet and record the result to report
+func (cv *Converter) runSheet(filename, sheet string, report *Report) bool {
+	skipped, err := cv.readSheet(filename, sheet)
+	if err != nil {
+		report.addError(toSheetError(filename, sheet, err))
+		cv.ForgetXlsx(filename) // make sure the file will be handled again next time
+		return false
+	}
+
+	report.addSheet(sheet, skipped)
+	return true
+}
+
+// isSheetsOutdated check if sheets in a xlsx file need to be converted again
+// all sheets are outdated once enums changed
+func (cv *Converter) isSheetsOutdated(filename string) (bool, error) {
+	changed, err := cv.IsXlsxChanged(filename)
+	return changed || cv.enumsChanged, err
+}
+
+// sheetJob is a line in xlsx*.config, sheets in one line are read into one message
+type sheetJob struct {
+	filename string
+	sheet    string
+}
+
+// outdatedJobs returns sheets of outdated xlsx files, sorted by file name
+// files which can not be hashed are recorded to report and skipped
+func (cv *Converter) outdatedJobs(report *Report) []sheetJob {
+	var jobs []sheetJob
+	for filename, sheets := range cv.sheetFileMap {
+		outdated, err := cv.isSheetsOutdated(filename)
+		if err != nil {
+			report.addError(toSheetError(filename, "", err))
+			cv.ForgetXlsx(filename)
+			continue
+		}
+		if outdated {
 			for _, sheet := range sheets {
-				err := ReadSheet(filename, sheet)
-				if err != nil {
-					// panic or continue as needed.
-					panic(err)
-				}
+				jobs = append(jobs, sheetJob{filename: filename, sheet: sheet})
 			}
 		}
 	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].filename < jobs[j].filename
+	})
+
+	return jobs
+}
+
+// sourcePaths returns paths of files in a config line, e.g. "a.xlsx|b.xlsx"
+func (cv *Converter) sourcePaths(filename string) []string {
+	var paths []string
+	for _, fn := range strings.Split(filename, "|") {
+		paths = append(paths, cv.cfg.sourcePath(fn))
+	}
+
+	return paths
+}
+
+// runJob read a sheet and release its files
+func (cv *Converter) runJob(job sheetJob, report *Report) bool {
+	defer cv.sources.release(cv.sourcePaths(job.filename))
+
+	return cv.runSheet(job.filename, job.sheet, report)
+}
+
+func (cv *Converter) runOneByOne(jobs []sheetJob, opts Options, report *Report) {
+	for _, job := range jobs {
+		if !cv.runJob(job, report) && opts.FailFast {
+			return
+		}
+	}
 }
 
-func runByGoroutine() {
+// runByWorkers read sheets in a pool of workers, sheets of a xlsx file may be read at the same time
+func (cv *Converter) runByWorkers(jobs []sheetJob, opts Options, report *Report) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
 	var wg sync.WaitGroup
+	var failed bool
+	var failedMutex sync.RWMutex
+
+	isFailed := func() bool {
+		failedMutex.RLock()
+		defer failedMutex.RUnlock()
+		return failed
+	}
 
-	for filename, sheets := range sheetFileMap {
-		if IsXlsxChanged(filename) {
-			wg.Add(1)
-			go func(filename string, sheets []string) {
-				defer wg.Done()
-				for _, sheet := range sheets {
-					err := ReadSheet(filename, sheet)
-					if err != nil {
-						// panic or continue as needed.
-						panic(err)
-					}
+	jobCh := make(chan sheetJob)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobCh {
+				if opts.FailFast && isFailed() {
+					cv.sources.release(cv.sourcePaths(job.filename))
+					cv.ForgetXlsx(job.filename)
+					continue
 				}
-			}(filename, sheets)
-		}
+				if !cv.runJob(job, report) {
+					failedMutex.Lock()
+					failed = true
+					failedMutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		jobCh <- job
 	}
+	close(jobCh)
 
 	wg.Wait()
 }
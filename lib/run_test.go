@@ -0,0 +1,121 @@
+package lib
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSheetError(t *testing.T) {
+	tests := []struct {
+		in       *SheetError
+		expected string
+	}{
+		{&SheetError{Err: ErrFormatInvalid}, "invalid cell value"},
+		{&SheetError{File: "Sample.xlsx", Sheet: "SAMPLEONE", Err: ErrFormatInvalid}, "Sample.xlsx, sheet SAMPLEONE: invalid cell value"},
+		{&SheetError{File: "Sample.xlsx", Sheet: "SAMPLEONE", Row: 5, Col: 28, Field: "Count", Err: ErrRequiredFieldEmpty},
+			"Sample.xlsx, sheet SAMPLEONE, row 5, col AB, field Count: required field is empty"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.in.Error())
+		assert.True(t, errors.Is(test.in, test.in.Err))
+	}
+}
+
+func TestToSheetError(t *testing.T) {
+	se := toSheetError("Sample.xlsx", "SAMPLEONE", &SheetError{Sheet: "SAMPLETWO", Row: 3, Err: ErrFormatInvalid})
+	assert.Equal(t, "Sample.xlsx", se.File)
+	assert.Equal(t, "SAMPLETWO", se.Sheet)
+	assert.Equal(t, 3, se.Row)
+
+	se = toSheetError("Sample.xlsx", "SAMPLEONE", ErrFormatInvalid)
+	assert.Equal(t, "SAMPLEONE", se.Sheet)
+	assert.Equal(t, ErrFormatInvalid, se.Err)
+}
+
+func TestRunUnreadableXlsx(t *testing.T) {
+	cfg := newTempConfig(t)
+	cfg.XlsxPath = t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(cfg.XlsxPath, "Broken.xlsx"), 0777)) // exists but can not be hashed
+	cv := newConverter(cfg)
+	cv.sheetFileMap = map[string][]string{"Broken.xlsx": {"BROKEN"}}
+
+	report, err := cv.Run(Options{UseCache: true, DryRun: true})
+	assert.Error(t, err)
+	assert.Equal(t, 1, len(report.Errors))
+	assert.Equal(t, "Broken.xlsx", report.Errors[0].File)
+	assert.Empty(t, report.Sheets)
+}
+
+func TestRunDryRun(t *testing.T) {
+	cfg := newTempConfig(t)
+	cv := newConverter(cfg)
+	cv.sheetFileMap = map[string][]string{"Sample.xlsx": {"SAMPLEONE", "SAMPLETWO"}}
+
+	report, err := cv.Run(Options{UseCache: true, DryRun: true})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"SAMPLEONE"}, report.Sheets)
+	assert.Equal(t, "SAMPLETWO", report.Errors[0].Sheet)
+
+	// xlsx is forgotten as SAMPLETWO failed
+	assert.Equal(t, []*Change{
+		{Kind: "proto", Name: "SAMPLEONE", State: New},
+		{Kind: "data", Name: "SAMPLEONE", State: New},
+	}, cv.Changes())
+
+	for _, pattern := range []string{cfg.ProtoOutPath + "/*", cfg.DataOutPath + "/*", cfg.CacheFile, cfg.LockFile} {
+		files, _ := filepath.Glob(pattern)
+		assert.Empty(t, files, pattern)
+	}
+}
+
+func TestRunByWorkers(t *testing.T) {
+	for _, workers := range []int{1, 3} {
+		cfg := newTempConfig(t)
+		cv := newConverter(cfg)
+		cv.sheetFileMap = map[string][]string{"Sample.xlsx": {"SAMPLEONE", "SAMPLETWO", "SAMPLEONE"}}
+
+		report, err := cv.Run(Options{UseGoroutine: true, Workers: workers, DryRun: true})
+		assert.Error(t, err)
+		assert.Equal(t, []string{"SAMPLEONE", "SAMPLEONE"}, report.Sheets)
+		assert.Len(t, report.Errors, 1)
+		assert.Nil(t, cv.sources)
+	}
+}
+
+func TestRunWriteFailed(t *testing.T) {
+	cfg := newTempConfig(t)
+	cfg.XlsxPath = t.TempDir()
+	run := func(name string) error {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(cfg.XlsxPath, "items.csv"), []byte(`unique,optional
+uint32,string
+ItemID,Name
+ID,
+1001,`+name+`
+`), 0644))
+		cv := newConverter(cfg)
+		assert.NoError(t, cv.readCfgLine("ITEM items.csv"))
+		_, err := cv.Run(Options{UseCache: true})
+		return err
+	}
+
+	assert.NoError(t, run("sword"))
+	dataFile := filepath.Join(cfg.DataOutPath, "item.data")
+	prev, err := ioutil.ReadFile(dataFile)
+	assert.NoError(t, err)
+
+	// previous data can not be compared, so new data is not written and its hash should not be kept in cache
+	assert.NoError(t, ioutil.WriteFile(dataFile, []byte{0xff}, 0644))
+	assert.Error(t, run("shield"))
+
+	assert.NoError(t, ioutil.WriteFile(dataFile, prev, 0644))
+	assert.NoError(t, run("shield"))
+	raw, err := ioutil.ReadFile(dataFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(raw), "shield")
+}
@@ -0,0 +1,265 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/tealeg/xlsx"
+)
+
+// Extensions of csv sources, other files are opened as xlsx
+const (
+	CSVExt = ".csv"
+	TSVExt = ".tsv"
+)
+
+// Source is a file containing one or more sheets
+type Source interface {
+	Sheet(name string) (Sheet, bool)
+}
+
+// Sheet is a table of cells, row and col index start from 0
+type Sheet interface {
+	Name() string
+	MaxRow() int
+	MaxCol() int
+	Cell(row, col int) string // empty if cell does not exist
+	Row(row int) []string     // trailing empty cells may be omitted
+}
+
+// sourceFile returns the file name of a source in config, xlsx ext is not added to csv files
+func (c *Config) sourceFile(filename string) string {
+	if isCSVFile(filename) {
+		return filename
+	}
+
+	return filename + c.XlsxExt
+}
+
+// sourcePath returns the full path of a source in config
+func (c *Config) sourcePath(filename string) string {
+	return filepath.Join(c.XlsxPath, c.sourceFile(filename))
+}
+
+func isCSVFile(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case CSVExt, TSVExt:
+		return true
+	}
+
+	return false
+}
+
+// openSource open a xlsx file, or a csv file if path ends with .csv or .tsv
+func openSource(path string) (Source, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case CSVExt:
+		return openCSV(path, ',')
+	case TSVExt:
+		return openCSV(path, '\t')
+	}
+
+	xlsxFile, err := xlsx.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &xlsxSource{file: xlsxFile}, nil
+}
+
+// sourceCache shares opened files between sheets in a run, a file is dropped after all its sheets are read
+type sourceCache struct {
+	mutex sync.Mutex
+	files map[string]*cachedSource
+}
+
+type cachedSource struct {
+	once   sync.Once
+	source Source
+	err    error
+	refs   int // count of sheets not read yet
+}
+
+func newSourceCache() *sourceCache {
+	return &sourceCache{files: make(map[string]*cachedSource)}
+}
+
+// acquire keep file opened until it is released by each sheet
+func (c *sourceCache) acquire(paths []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, path := range paths {
+		f, ok := c.files[path]
+		if !ok {
+			f = new(cachedSource)
+			c.files[path] = f
+		}
+		f.refs++
+	}
+}
+
+func (c *sourceCache) release(paths []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, path := range paths {
+		if f, ok := c.files[path]; ok {
+			if f.refs--; f.refs <= 0 {
+				delete(c.files, path)
+			}
+		}
+	}
+}
+
+// open returns the shared file, files not acquired or a nil cache open a new one
+func (c *sourceCache) open(path string) (Source, error) {
+	if c == nil {
+		return openSource(path)
+	}
+
+	c.mutex.Lock()
+	f, ok := c.files[path]
+	c.mutex.Unlock()
+	if !ok {
+		return openSource(path)
+	}
+
+	f.once.Do(func() {
+		f.source, f.err = openSource(path)
+	})
+
+	return f.source, f.err
+}
+
+type xlsxSource struct {
+	file *xlsx.File
+}
+
+func (s *xlsxSource) Sheet(name string) (Sheet, bool) {
+	sheet, ok := s.file.Sheet[name]
+	if !ok {
+		return nil, false
+	}
+
+	return &xlsxSheet{sheet: sheet}, true
+}
+
+type xlsxSheet struct {
+	sheet *xlsx.Sheet
+}
+
+func (s *xlsxSheet) Name() string {
+	return s.sheet.Name
+}
+
+func (s *xlsxSheet) MaxRow() int {
+	return s.sheet.MaxRow
+}
+
+func (s *xlsxSheet) MaxCol() int {
+	return s.sheet.MaxCol
+}
+
+func (s *xlsxSheet) Cell(row, col int) string {
+	if row >= len(s.sheet.Rows) || col >= len(s.sheet.Rows[row].Cells) {
+		return ""
+	}
+
+	return s.sheet.Rows[row].Cells[col].Value
+}
+
+func (s *xlsxSheet) Row(row int) []string {
+	if row >= len(s.sheet.Rows) {
+		return nil
+	}
+
+	cells := s.sheet.Rows[row].Cells
+	values := make([]string, len(cells))
+	for i, cell := range cells {
+		values[i] = cell.Value
+	}
+
+	return values
+}
+
+// csvSource is a csv or tsv file, which contains only one sheet named in config
+type csvSource struct {
+	records [][]string
+}
+
+func openCSV(path string, comma rune) (*csvSource, error) {
+	rawData, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// files saved by Excel start with UTF-8 BOM
+	rawData = bytes.TrimPrefix(rawData, []byte("\xef\xbb\xbf"))
+
+	r := csv.NewReader(bytes.NewReader(rawData))
+	r.Comma = comma
+	r.FieldsPerRecord = -1 // trailing empty cells are often omitted
+	r.LazyQuotes = comma == '\t'
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read %s fail, %v", path, err)
+	}
+
+	return &csvSource{records: records}, nil
+}
+
+func (s *csvSource) Sheet(name string) (Sheet, bool) {
+	return newCSVSheet(name, s.records), true
+}
+
+type csvSheet struct {
+	name    string
+	records [][]string
+	maxCol  int
+}
+
+func newCSVSheet(name string, records [][]string) *csvSheet {
+	sheet := &csvSheet{name: name, records: records}
+	for _, record := range records {
+		if len(record) > sheet.maxCol {
+			sheet.maxCol = len(record)
+		}
+	}
+
+	return sheet
+}
+
+func (s *csvSheet) Name() string {
+	return s.name
+}
+
+func (s *csvSheet) MaxRow() int {
+	return len(s.records)
+}
+
+func (s *csvSheet) MaxCol() int {
+	return s.maxCol
+}
+
+func (s *csvSheet) Cell(row, col int) string {
+	if row >= len(s.records) || col >= len(s.records[row]) {
+		return ""
+	}
+
+	return s.records[row][col]
+}
+
+func (s *csvSheet) Row(row int) []string {
+	if row >= len(s.records) {
+		return nil
+	}
+
+	return s.records[row]
+}
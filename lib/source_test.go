@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var itemRows = [][]string{
+	{"unique", "required", "optional"},
+	{"uint32", "string", "float"},
+	{"ItemID", "Name", "Weight"},
+	{"ID", "Name, shown in bag", "Weight"},
+	{"1001", "Sword, long", "3.5"},
+	{"1002", "Shield"},
+}
+
+func TestOpenCSV(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "items.csv")
+	tsvFile := filepath.Join(dir, "items.tsv")
+
+	// BOM is written by Excel, second row omits trailing empty cell
+	assert.NoError(t, ioutil.WriteFile(csvFile, []byte("\xef\xbb\xbf"+`unique,required,optional
+uint32,string,float
+ItemID,Name,Weight
+ID,"Name, shown in bag",Weight
+1001,"Sword, long",3.5
+1002,Shield
+`), 0644))
+	assert.NoError(t, ioutil.WriteFile(tsvFile, []byte("unique\trequired\toptional\n"+
+		"uint32\tstring\tfloat\n"+
+		"ItemID\tName\tWeight\n"+
+		"ID\tName, shown in bag\tWeight\n"+
+		"1001\tSword, long\t3.5\n"+
+		"1002\tShield\n"), 0644))
+
+	expected := newProtoRow(newTestConfig())
+	assert.NoError(t, expected.updateHeads(newTestSheet("ITEM", itemRows)))
+	assert.NoError(t, expected.readData(newTestSheet("ITEM", itemRows)))
+
+	for _, file := range []string{csvFile, tsvFile} {
+		source, err := openSource(file)
+		assert.NoError(t, err, file)
+
+		sheet, ok := source.Sheet("ITEM")
+		assert.True(t, ok)
+		assert.Equal(t, "ITEM", sheet.Name())
+		assert.Equal(t, 6, sheet.MaxRow())
+		assert.Equal(t, 3, sheet.MaxCol())
+		assert.Equal(t, "", sheet.Cell(5, 2))
+		assert.Equal(t, "unique", sheet.Cell(RowAttr, 0))
+
+		pr := newProtoRow(newTestConfig())
+		assert.NoError(t, pr.updateHeads(sheet))
+		assert.NoError(t, pr.readData(sheet))
+		assert.Equal(t, expected.buf.Bytes(), pr.buf.Bytes(), file)
+	}
+}
+
+func TestReadSheetCSV(t *testing.T) {
+	cfg := newTempConfig(t)
+	cfg.XlsxPath = t.TempDir()
+	cfg.XlsxExt = ".xlsx" // not added to csv files
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cfg.XlsxPath, "items.csv"), []byte(`unique,required
+uint32,string
+ItemID,Name
+ID,Name
+1001,Sword
+`), 0644))
+
+	cv := newConverter(cfg)
+	assert.True(t, cv.IsSheetExists("items.csv"))
+	assert.NoError(t, cv.ReadSheet("items.csv", "ITEM"))
+
+	_, err := os.Stat(filepath.Join(cfg.DataOutPath, "item.data"))
+	assert.NoError(t, err)
+}
+
+func TestSourceCache(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "items.csv")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("unique\nuint32\nItemID\n\n1001\n"), 0644))
+
+	cache := newSourceCache()
+	cache.acquire([]string{file})
+	cache.acquire([]string{file})
+
+	first, err := cache.open(file)
+	assert.NoError(t, err)
+	second, err := cache.open(file)
+	assert.NoError(t, err)
+	assert.True(t, first == second)
+
+	cache.release([]string{file})
+	assert.Contains(t, cache.files, file)
+	cache.release([]string{file})
+	assert.NotContains(t, cache.files, file)
+
+	// files not acquired are opened every time
+	third, err := cache.open(file)
+	assert.NoError(t, err)
+	assert.False(t, first == third)
+
+	var nilCache *sourceCache
+	_, err = nilCache.open(file)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,534 @@
+package lib
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/tealeg/xlsx"
+)
+
+// RowReader iterates rows of a sheet without loading the whole file
+type RowReader interface {
+	Next() (int, []string, error) // index of row starts from 0, io.EOF after the last row
+	Close() error
+}
+
+// isStreamSheet check if any of sheets in a config line is read in streaming mode
+func (c *Config) isStreamSheet(sheetNames []string) bool {
+	for _, name := range sheetNames {
+		if contains(c.StreamSheets, strings.TrimSpace(name)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkStream returns error if streamed sheets are used with outputs which need all rows in memory
+func (c *Config) checkStream() error {
+	if len(c.StreamSheets) == 0 {
+		return nil
+	}
+
+	var options []string
+	for _, option := range []struct {
+		name string
+		used bool
+	}{
+		{"targets", len(c.Targets) > 0},
+		{"json_path", c.JSONOutPath != ""},
+		{"lua_path", c.LuaOutPath != ""},
+		{"text_dump", c.TextDump},
+		{"row_change_log", c.RowChangeLog != ""},
+	} {
+		if option.used {
+			options = append(options, option.name)
+		}
+	}
+	if len(options) > 0 {
+		return fmt.Errorf("stream_sheets can not be used with %s", strings.Join(options, ", "))
+	}
+
+	return nil
+}
+
+// readStream read sheets row by row, encoded rows are written to a temp data file instead of kept in memory
+// the temp file replaces data file only if data changed
+func (cv *Converter) readStream(fileName, preName string, files, sheetNames []string) (int, error) {
+	if len(cv.cfg.Targets) > 0 {
+		return 0, fmt.Errorf("streamed sheets %v can not be exported to targets", sheetNames)
+	}
+
+	pr := newProtoRow(cv.cfg)
+	pr.enums = cv.enums
+	pr.Name = preName
+	if pr.Name == "" {
+		pr.Name = strings.TrimSpace(sheetNames[0])
+	}
+	pr.lock = cv.messageLock(pr.Name)
+
+	stream, err := newDataStream(cv.cfg.DataOutPath, cv.dryRun)
+	if err != nil {
+		return 0, err
+	}
+	defer stream.discard()
+	pr.stream = stream
+
+	for _, fn := range files {
+		fmt.Printf("streaming %v for sheets %v\n", fn, strings.Join(sheetNames, ","))
+
+		fullName := cv.cfg.sourcePath(fn)
+		if _, err := os.Stat(fullName); os.IsNotExist(err) {
+			return 0, fmt.Errorf("file %s does not exists", fn)
+		}
+
+		for _, sheetName := range sheetNames {
+			if err := pr.readRows(fullName, sheetName); err != nil {
+				return 0, toSheetError("", sheetName, err)
+			}
+		}
+	}
+	pr.dataHash = stream.sum()
+
+	return cv.finishSheets(fileName, pr)
+}
+
+// readRows read heads and data of a sheet row by row, each encoded row is flushed to stream
+func (pr *ProtoSheet) readRows(path, sheetName string) error {
+	rows, err := openRowReader(path, sheetName)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var heads [][]string
+	started := false
+	start := func() error {
+		if len(heads) == 0 {
+			return fmt.Errorf("sheet %v contains no data", sheetName)
+		}
+		if err := pr.updateHeads(newCSVSheet(sheetName, heads)); err != nil {
+			return err
+		}
+		if err := pr.BuildDescriptor(); err != nil {
+			return err
+		}
+		pr.updateUniqueKeys()
+		started = true
+		return nil
+	}
+
+	for {
+		i, row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if i < RowData {
+			for len(heads) < i { // rows without any cell are not in file
+				heads = append(heads, nil)
+			}
+			heads = append(heads, row)
+			continue
+		}
+
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := pr.readDataRow(sheetName, i, row); err != nil {
+			return err
+		}
+		if err := pr.stream.flush(pr.buf); err != nil {
+			return err
+		}
+	}
+
+	if !started {
+		return start()
+	}
+
+	return nil
+}
+
+// dataStream writes encoded rows to a temp file in data dir, and hashes them as they are written
+type dataStream struct {
+	file *os.File // nil in dry run
+	w    *bufio.Writer
+	hash hash.Hash
+}
+
+func newDataStream(dir string, dryRun bool) (*dataStream, error) {
+	s := &dataStream{hash: md5.New()}
+	if dryRun {
+		return s, nil
+	}
+
+	f, err := ioutil.TempFile(dir, ".stream-*")
+	if err != nil {
+		return nil, err
+	}
+	s.file = f
+	s.w = bufio.NewWriter(f)
+
+	return s, nil
+}
+
+// flush move encoded rows in buf to file
+func (s *dataStream) flush(buf *proto.Buffer) error {
+	raw := buf.Bytes()
+	s.hash.Write(raw)
+	if s.w != nil {
+		if _, err := s.w.Write(raw); err != nil {
+			return err
+		}
+	}
+	buf.Reset()
+
+	return nil
+}
+
+func (s *dataStream) sum() []byte {
+	return s.hash.Sum(nil)
+}
+
+// commit replace data file with the temp file
+func (s *dataStream) commit(dataFile string) error {
+	if s.file == nil {
+		return fmt.Errorf("no streamed data for %s", dataFile)
+	}
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(s.file.Name(), dataFile); err != nil {
+		return err
+	}
+	s.file = nil
+
+	return nil
+}
+
+// discard remove the temp file if it is not committed
+func (s *dataStream) discard() {
+	if s.file == nil {
+		return
+	}
+
+	s.file.Close()
+	os.Remove(s.file.Name())
+	s.file = nil
+}
+
+// openRowReader open a sheet of xlsx file, or a csv file, to be read row by row
+func openRowReader(path, sheetName string) (RowReader, error) {
+	if isCSVFile(path) {
+		return openCSVRows(path)
+	}
+
+	return openXlsxRows(path, sheetName)
+}
+
+type csvRowReader struct {
+	file *os.File
+	r    *csv.Reader
+	idx  int
+}
+
+func openCSVRows(path string) (*csvRowReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(f)
+	// files saved by Excel start with UTF-8 BOM
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		br.Discard(3)
+	}
+
+	r := csv.NewReader(br)
+	r.FieldsPerRecord = -1
+	if strings.ToLower(filepath.Ext(path)) == TSVExt {
+		r.Comma = '\t'
+		r.LazyQuotes = true
+	}
+
+	return &csvRowReader{file: f, r: r}, nil
+}
+
+func (r *csvRowReader) Next() (int, []string, error) {
+	record, err := r.r.Read()
+	if err != nil {
+		return 0, nil, err
+	}
+	r.idx++
+
+	return r.idx - 1, record, nil
+}
+
+func (r *csvRowReader) Close() error {
+	return r.file.Close()
+}
+
+// xlsxRowReader decode rows from sheet xml in xlsx file, only shared strings are loaded in memory
+type xlsxRowReader struct {
+	zip     *zip.ReadCloser
+	sheet   io.ReadCloser
+	dec     *xml.Decoder
+	strings []string
+	lastRow int
+}
+
+type xlsxStreamRow struct {
+	R     int              `xml:"r,attr"`
+	Cells []xlsxStreamCell `xml:"c"`
+}
+
+type xlsxStreamCell struct {
+	R  string            `xml:"r,attr"`
+	T  string            `xml:"t,attr"`
+	V  string            `xml:"v"`
+	Is *xlsxStreamString `xml:"is"`
+}
+
+// xlsxStreamString is a shared string or an inline string, rich text is kept in runs
+type xlsxStreamString struct {
+	T string `xml:"t"`
+	R []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (s *xlsxStreamString) String() string {
+	if len(s.R) == 0 {
+		return s.T
+	}
+
+	var sb strings.Builder
+	for _, r := range s.R {
+		sb.WriteString(r.T)
+	}
+
+	return sb.String()
+}
+
+func openXlsxRows(path, sheetName string) (*xlsxRowReader, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &xlsxRowReader{zip: zr, lastRow: -1}
+	if err := r.open(sheetName); err != nil {
+		zr.Close()
+		return nil, fmt.Errorf("open %s fail, %v", path, err)
+	}
+
+	return r, nil
+}
+
+// open find sheet xml by name in workbook, and load shared strings
+func (r *xlsxRowReader) open(sheetName string) error {
+	files := make(map[string]*zip.File, len(r.zip.File))
+	for _, f := range r.zip.File {
+		files[f.Name] = f
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodeZipFile(files["xl/workbook.xml"], &workbook); err != nil {
+		return err
+	}
+	var rels struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeZipFile(files["xl/_rels/workbook.xml.rels"], &rels); err != nil {
+		return err
+	}
+
+	var target string
+	for _, sheet := range workbook.Sheets {
+		if sheet.Name != sheetName {
+			continue
+		}
+		for _, rel := range rels.Rels {
+			if rel.ID == sheet.ID {
+				target = rel.Target
+			}
+		}
+	}
+	if target == "" {
+		return fmt.Errorf("xlsx file does not contain sheet %s", sheetName)
+	}
+	if strings.HasPrefix(target, "/") {
+		target = strings.TrimPrefix(target, "/")
+	} else {
+		target = path.Join("xl", target)
+	}
+
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := r.loadStrings(f); err != nil {
+			return err
+		}
+	}
+
+	f, ok := files[target]
+	if !ok {
+		return fmt.Errorf("%s not found", target)
+	}
+	sheet, err := f.Open()
+	if err != nil {
+		return err
+	}
+	r.sheet = sheet
+	r.dec = xml.NewDecoder(sheet)
+
+	return nil
+}
+
+func (r *xlsxRowReader) loadStrings(f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "si" {
+			var si xlsxStreamString
+			if err := dec.DecodeElement(&si, &se); err != nil {
+				return err
+			}
+			r.strings = append(r.strings, si.String())
+		}
+	}
+}
+
+func (r *xlsxRowReader) Next() (int, []string, error) {
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return 0, nil, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "row" {
+			continue
+		}
+
+		var row xlsxStreamRow
+		if err := r.dec.DecodeElement(&row, &se); err != nil {
+			return 0, nil, err
+		}
+		if row.R > 0 {
+			r.lastRow = row.R - 1
+		} else {
+			r.lastRow++
+		}
+
+		values, err := r.rowValues(row.Cells)
+		if err != nil {
+			return 0, nil, fmt.Errorf("row %d, %v", r.lastRow+1, err)
+		}
+
+		return r.lastRow, values, nil
+	}
+}
+
+// rowValues convert cells to values, the same as cell values read by xlsx.OpenFile
+func (r *xlsxRowReader) rowValues(cells []xlsxStreamCell) ([]string, error) {
+	var values []string
+	for _, cell := range cells {
+		col := len(values)
+		if cell.R != "" {
+			x, _, err := xlsx.GetCoordsFromCellIDString(cell.R)
+			if err != nil {
+				return nil, err
+			}
+			col = x
+		}
+		for len(values) <= col {
+			values = append(values, "")
+		}
+
+		val := strings.Trim(cell.V, " \t\n\r")
+		switch cell.T {
+		case "s":
+			if val == "" {
+				continue
+			}
+			idx, err := strconv.Atoi(val)
+			if err != nil || idx < 0 || idx >= len(r.strings) {
+				return nil, fmt.Errorf("invalid shared string %s in cell %s", val, cell.R)
+			}
+			values[col] = r.strings[idx]
+		case "inlineStr":
+			if cell.Is != nil && cell.Is.T != "" {
+				values[col] = strings.Trim(cell.Is.T, " \t\n\r")
+			} else if cell.Is != nil {
+				values[col] = cell.Is.String()
+			}
+		default:
+			values[col] = val
+		}
+	}
+
+	return values, nil
+}
+
+func (r *xlsxRowReader) Close() error {
+	if r.sheet != nil {
+		r.sheet.Close()
+	}
+
+	return r.zip.Close()
+}
+
+func decodeZipFile(f *zip.File, v interface{}) error {
+	if f == nil {
+		return fmt.Errorf("invalid xlsx file")
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return xml.NewDecoder(rc).Decode(v)
+}
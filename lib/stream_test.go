@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXlsxRowReader(t *testing.T) {
+	source, err := openSource("../test/Sample.xlsx")
+	assert.NoError(t, err)
+	sheet, ok := source.Sheet("SAMPLEONE")
+	assert.True(t, ok)
+
+	rows, err := openXlsxRows("../test/Sample.xlsx", "SAMPLEONE")
+	assert.NoError(t, err)
+	defer rows.Close()
+
+	count := 0
+	for {
+		i, row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		for j := 0; j < sheet.MaxCol(); j++ {
+			var cell string
+			if j < len(row) {
+				cell = row[j]
+			}
+			assert.Equal(t, sheet.Cell(i, j), cell, "row %d col %d", i, j)
+		}
+		count++
+	}
+	assert.Equal(t, sheet.MaxRow(), count)
+
+	_, err = openXlsxRows("../test/Sample.xlsx", "NOSUCHSHEET")
+	assert.Error(t, err)
+}
+
+func TestReadStream(t *testing.T) {
+	read := func(stream bool) ([]byte, []byte) {
+		cfg := newTempConfig(t)
+		if stream {
+			cfg.StreamSheets = []string{"SAMPLEONE"}
+		}
+		cv := newConverter(cfg)
+		cv.cacher = newCacher()
+		assert.NoError(t, cv.ReadSheet("Sample.xlsx", "SAMPLEONE"))
+
+		data, err := ioutil.ReadFile(filepath.Join(cfg.DataOutPath, "sampleone.data"))
+		assert.NoError(t, err)
+		temps, _ := filepath.Glob(filepath.Join(cfg.DataOutPath, ".stream-*"))
+		assert.Empty(t, temps)
+
+		return data, cv.cacher.DataInfos["SAMPLEONE"].MD5
+	}
+
+	data, hash := read(false)
+	streamData, streamHash := read(true)
+	assert.Equal(t, data, streamData)
+	assert.Equal(t, hash, streamHash)
+}
+
+func TestReadStreamCSV(t *testing.T) {
+	cfg := newTempConfig(t)
+	cfg.XlsxPath = t.TempDir()
+	cfg.StreamSheets = []string{"ITEM"}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cfg.XlsxPath, "items.csv"), []byte(`unique,required,optional
+uint32,string,float
+ItemID,Name,Weight
+ID,"Name, shown in bag",Weight
+1001,"Sword, long",3.5
+#1003,Disabled,1
+1002,Shield
+`), 0644))
+
+	expected := newProtoRow(newTestConfig())
+	assert.NoError(t, expected.updateHeads(newTestSheet("ITEM", itemRows)))
+	assert.NoError(t, expected.readData(newTestSheet("ITEM", itemRows)))
+
+	cv := newConverter(cfg)
+	skipped, err := cv.readSheet("items.csv", "ITEM")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, skipped)
+
+	data, err := ioutil.ReadFile(filepath.Join(cfg.DataOutPath, "item.data"))
+	assert.NoError(t, err)
+	assert.Equal(t, expected.buf.Bytes(), data)
+
+	cfg.Targets = []string{"client"}
+	_, err = cv.readSheet("items.csv", "ITEM")
+	assert.Error(t, err)
+}
+
+func TestCheckStream(t *testing.T) {
+	cfg := newTestConfig()
+	assert.NoError(t, cfg.checkStream())
+
+	cfg.JSONOutPath = "../test/json"
+	cfg.TextDump = true
+	assert.NoError(t, cfg.checkStream())
+
+	cfg.StreamSheets = []string{"MONSTERSPAWN"}
+	assert.EqualError(t, cfg.checkStream(), "stream_sheets can not be used with json_path, text_dump")
+
+	cfg.JSONOutPath, cfg.TextDump = "", false
+	assert.NoError(t, cfg.checkStream())
+}
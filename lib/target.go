@@ -0,0 +1,191 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// TargetSep separates column attribute and export targets, e.g. "optional@server" or "required@client,server"
+const TargetSep = "@"
+
+var ErrUnknownTarget = errors.New("unknown export target")
+
+// splitAttr split attribute cell into attribute and export targets, targets are empty if column is for all targets
+func splitAttr(attrCell string) (string, []string) {
+	parts := strings.SplitN(attrCell, TargetSep, 2)
+	if len(parts) == 1 {
+		return attrCell, nil
+	}
+
+	var targets []string
+	for _, target := range strings.Split(parts[1], ",") {
+		if target = strings.TrimSpace(target); target != "" {
+			targets = append(targets, target)
+		}
+	}
+
+	return strings.TrimSpace(parts[0]), targets
+}
+
+// checkTargets check if targets of a column are declared in config
+func (pr *ProtoSheet) checkTargets(colIdx int, targets []string) error {
+	if len(pr.cfg.Targets) == 0 {
+		return nil
+	}
+
+	for _, target := range targets {
+		if !contains(pr.cfg.Targets, target) {
+			return &SheetError{Col: colIdx + 1, Err: fmt.Errorf("%w %s, targets in config are %v", ErrUnknownTarget, target, pr.cfg.Targets)}
+		}
+	}
+
+	return nil
+}
+
+// exports check if a column is exported to target
+func (info *CommonInfo) exports(target string) bool {
+	return len(info.targets) == 0 || contains(info.targets, target)
+}
+
+// forTarget returns a copy of config whose output paths are sub dirs named by target
+func (cfg *Config) forTarget(target string) *Config {
+	c := *cfg
+	c.ProtoOutPath = filepath.Join(c.ProtoOutPath, target)
+	c.DataOutPath = filepath.Join(c.DataOutPath, target)
+	if c.JSONOutPath != "" {
+		c.JSONOutPath = filepath.Join(c.JSONOutPath, target)
+	}
+	if c.GoOutPath != "" {
+		c.GoOutPath = filepath.Join(c.GoOutPath, target)
+	}
+	if c.CSharpOutPath != "" {
+		c.CSharpOutPath = filepath.Join(c.CSharpOutPath, target)
+	}
+	if c.LuaOutPath != "" {
+		c.LuaOutPath = filepath.Join(c.LuaOutPath, target)
+	}
+
+	return &c
+}
+
+// outputName returns name of output files used in cache, e.g. "client/SAMPLEONE"
+func (pr *ProtoSheet) outputName() string {
+	if pr.target == "" {
+		return pr.Name
+	}
+
+	return pr.target + "/" + pr.Name
+}
+
+// forTarget returns a sheet only contains columns exported to target, which outputs to sub dirs named by target
+// field numbers and data are the same as current sheet, fields of other targets are dropped
+func (pr *ProtoSheet) forTarget(target string) (*ProtoSheet, error) {
+	tp := newProtoRow(pr.cfg.forTarget(target))
+	tp.Name = pr.Name
+	tp.target = target
+	tp.enums = pr.enums
+	tp.lock = pr.lock // removed fields are reserved in protos of all targets
+
+	for _, val := range pr.vars {
+		if val.exports(target) {
+			tp.vars = append(tp.vars, val)
+		}
+	}
+	for _, optS := range pr.optStructs {
+		if optS.exports(target) {
+			tp.optStructs = append(tp.optStructs, optS.forTarget(target))
+		}
+	}
+	for _, repeat := range pr.repeats {
+		if !repeat.exports(target) {
+			continue
+		}
+		rp := *repeat
+		if repeat.opts != nil {
+			rp.opts = repeat.opts.forTarget(target)
+		}
+		tp.repeats = append(tp.repeats, &rp)
+	}
+
+	if err := tp.GenProto(); err != nil {
+		return nil, err
+	}
+	tp.ProtoHash()
+
+	// fields of other targets are unknown fields of target message
+	array := dynamicpb.NewMessage(tp.arrayDesc)
+	if err := (protov2.UnmarshalOptions{AllowPartial: true}).Unmarshal(pr.buf.Bytes(), array); err != nil {
+		return nil, err
+	}
+	list := array.Get(tp.arrayDesc.Fields().ByNumber(1)).List()
+	for i := 0; i < list.Len(); i++ {
+		if !pr.rowExports(i, target) {
+			continue
+		}
+		item := list.Get(i).Message()
+		dropUnknown(item)
+
+		rawRowData, err := marshalOptions.Marshal(item.Interface())
+		if err != nil {
+			return nil, err
+		}
+		if len(rawRowData) != 0 {
+			// the same as readData
+			if err := tp.buf.EncodeVarint(uint64(10)); err != nil {
+				return nil, err
+			}
+			if err := tp.buf.EncodeRawBytes(rawRowData); err != nil {
+				return nil, err
+			}
+		}
+	}
+	tp.DataHash()
+
+	return tp, nil
+}
+
+// forTarget returns a copy of optional struct only contains fields exported to target
+func (optS *OptStruct) forTarget(target string) *OptStruct {
+	c := *optS
+	c.fields = nil
+	for _, val := range optS.fields {
+		if val.exports(target) {
+			c.fields = append(c.fields, val)
+		}
+	}
+
+	return &c
+}
+
+// dropUnknown remove unknown fields of a message and its nested messages
+func dropUnknown(m protoreflect.Message) {
+	m.SetUnknown(nil)
+	m.Range(func(field protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case field.IsMap() || field.Message() == nil:
+		case field.IsList():
+			for i := 0; i < v.List().Len(); i++ {
+				dropUnknown(v.List().Get(i).Message())
+			}
+		default:
+			dropUnknown(v.Message())
+		}
+		return true
+	})
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
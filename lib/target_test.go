@@ -0,0 +1,175 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitAttr(t *testing.T) {
+	attr, targets := splitAttr("optional")
+	assert.Equal(t, "optional", attr)
+	assert.Empty(t, targets)
+
+	attr, targets = splitAttr("required@client, server")
+	assert.Equal(t, "required", attr)
+	assert.Equal(t, []string{"client", "server"}, targets)
+}
+
+func TestForTarget(t *testing.T) {
+	sheet := newTestSheet("TARGETTEST", [][]string{
+		{"unique", "optional@client", "optional@server", "optional_struct", "optional", "optional@server", "optional@client"},
+		{"uint32", "string", "float", "2", "uint32", "uint32", "list<string>"},
+		{"ID", "Icon", "DropRate", "Limit", "Count", "MaxSpeed", "Tags"},
+		{"", "", "", "", "", "", ""},
+		{"1", "sword.png", "0.5", "", "3", "20", "a;b"},
+		{"2", "", "0.1", "", "", "30", ""},
+	})
+
+	cfg := newTestConfig()
+	cfg.Targets = []string{"client", "server"}
+	pr := newProtoRow(cfg)
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.readData(sheet))
+
+	tests := []struct {
+		target   string
+		json     string
+		protos   []string
+		excluded []string
+	}{
+		{
+			"client",
+			`[{"ID":1,"Icon":"sword.png","limits":{"Count":3},"Tags":["a","b"]},{"ID":2,"limits":{}}]`,
+			[]string{"  optional string Icon = 2 [default = \"\"];", "  repeated string Tags = 5;"},
+			[]string{"DropRate", "MaxSpeed"},
+		},
+		{
+			"server",
+			`[{"ID":1,"DropRate":0.5,"limits":{"Count":3,"MaxSpeed":20}},{"ID":2,"DropRate":0.1,"limits":{"MaxSpeed":30}}]`,
+			[]string{"  optional float DropRate = 3 [default = 0];", "    optional uint32 MaxSpeed = 2 [default = 0];"},
+			[]string{"Icon", "Tags"},
+		},
+	}
+
+	for _, test := range tests {
+		tp, err := pr.forTarget(test.target)
+		assert.NoError(t, err)
+		assert.Equal(t, test.target+"/TARGETTEST", tp.outputName())
+		assert.Equal(t, filepath.Join(cfg.ProtoOutPath, test.target), tp.cfg.ProtoOutPath)
+
+		items, err := tp.decodeItems()
+		assert.NoError(t, err)
+		raw, err := json.Marshal(items)
+		assert.NoError(t, err)
+		assert.Equal(t, test.json, string(raw), test.target)
+
+		for _, line := range test.protos {
+			assert.Contains(t, tp.outProto, line, test.target)
+		}
+		for _, line := range tp.outProto {
+			for _, name := range test.excluded {
+				assert.NotContains(t, line, name, test.target)
+			}
+		}
+	}
+}
+
+func TestTargetReserved(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Targets = []string{"client", "server"}
+	lock := newMessageLock()
+	read := func(rows [][]string) *ProtoSheet {
+		pr := newProtoRow(cfg)
+		pr.lock = lock
+		sheet := newTestSheet("TARGETTEST", rows)
+		assert.NoError(t, pr.updateHeads(sheet))
+		assert.NoError(t, pr.readData(sheet))
+		pr.reserveUnusedFields()
+		assert.NoError(t, pr.GenProto())
+		return pr
+	}
+
+	read([][]string{
+		{"unique", "optional@client", "optional"},
+		{"uint32", "string", "uint32"},
+		{"ID", "Icon", "Count"},
+		{"", "", ""},
+		{"1", "sword.png", "3"},
+	})
+	// Icon is removed, its number can not be used by client again
+	pr := read([][]string{
+		{"unique", "optional"},
+		{"uint32", "uint32"},
+		{"ID", "Count"},
+		{"", ""},
+		{"1", "3"},
+	})
+	for _, target := range cfg.Targets {
+		tp, err := pr.forTarget(target)
+		assert.NoError(t, err)
+		assert.Contains(t, tp.outProto, "  reserved 2;", target)
+		assert.Contains(t, tp.outProto, "  reserved \"Icon\";", target)
+	}
+}
+
+func TestUnknownTarget(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Targets = []string{"client", "server"}
+	pr := newProtoRow(cfg)
+	err := pr.updateHeads(newTestSheet("TARGETTEST", [][]string{
+		{"unique", "optional@editor"},
+		{"uint32", "string"},
+		{"ID", "Note"},
+		{"", ""},
+	}))
+	assert.True(t, errors.Is(err, ErrUnknownTarget))
+}
+
+func TestRunTargets(t *testing.T) {
+	cfg := newTempConfig(t)
+	cfg.XlsxPath = t.TempDir()
+	cfg.Targets = []string{"client", "server"}
+	assert.NoError(t, cfg.CheckDirs())
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cfg.XlsxPath, "items.csv"), []byte(`unique,optional@server
+uint32,float
+ItemID,DropRate
+ID,
+1001,0.5
+`), 0644))
+
+	cv := newConverter(cfg)
+	assert.NoError(t, cv.readCfgLine("ITEM items.csv"))
+	_, err := cv.Run(Options{UseCache: true})
+	assert.NoError(t, err)
+
+	for _, target := range cfg.Targets {
+		for _, file := range []string{
+			filepath.Join(cfg.ProtoOutPath, target, "item.proto"),
+			filepath.Join(cfg.ProtoOutPath, target, "item.pb"),
+			filepath.Join(cfg.DataOutPath, target, "item.data"),
+			filepath.Join(cfg.ChangeOutputPath, "proto", target, "item.proto"),
+			filepath.Join(cfg.ChangeOutputPath, "data", target, "item.data"),
+		} {
+			_, err := os.Stat(file)
+			assert.NoError(t, err, file)
+		}
+	}
+	_, err = os.Stat(filepath.Join(cfg.ProtoOutPath, "item.proto"))
+	assert.True(t, os.IsNotExist(err))
+
+	var buf bytes.Buffer
+	assert.NoError(t, cv.Decode("ITEM", "server", &buf))
+	assert.Contains(t, buf.String(), "DropRate:")
+	assert.True(t, errors.Is(cv.Decode("ITEM", "", ioutil.Discard), ErrUnknownTarget))
+	assert.True(t, errors.Is(cv.Decode("ITEM", "web", ioutil.Discard), ErrUnknownTarget))
+	assert.NoError(t, cv.Clean())
+	_, err = os.Stat(filepath.Join(cfg.DataOutPath, "client", "item.data"))
+	assert.True(t, os.IsNotExist(err))
+}
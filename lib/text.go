@@ -0,0 +1,209 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// TextExt is the ext of data dumped in protobuf text format, written next to data files
+const TextExt = ".txtpb"
+
+// MarshalText encode data of XXX_ARRAY message in protobuf text format, output is stable so it can be diffed
+func (pr *ProtoSheet) MarshalText() ([]byte, error) {
+	if pr.arrayDesc == nil {
+		if err := pr.BuildDescriptor(); err != nil {
+			return nil, err
+		}
+	}
+
+	array := dynamicpb.NewMessage(pr.arrayDesc)
+	if err := (protov2.UnmarshalOptions{AllowPartial: true}).Unmarshal(pr.buf.Bytes(), array); err != nil {
+		return nil, err
+	}
+
+	return marshalText(array), nil
+}
+
+// marshalText encode a message in protobuf text format
+// prototext is not used as it randomizes spaces on purpose, fields are in declaration order and map entries are sorted by key
+func marshalText(m protoreflect.Message) []byte {
+	var buf bytes.Buffer
+	writeTextFields(&buf, m, "")
+
+	return buf.Bytes()
+}
+
+func writeTextFields(buf *bytes.Buffer, m protoreflect.Message, indent string) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if !m.Has(field) {
+			continue
+		}
+
+		name := string(field.Name())
+		if field.Kind() == protoreflect.GroupKind {
+			name = string(field.Message().Name())
+		}
+
+		switch {
+		case field.IsMap():
+			entries := m.Get(field).Map()
+			for _, key := range sortedMapKeys(entries) {
+				fmt.Fprintf(buf, "%s%s: {\n", indent, name)
+				writeTextValue(buf, field.MapKey(), "key", key.Value(), indent+INDENT)
+				writeTextValue(buf, field.MapValue(), "value", entries.Get(key), indent+INDENT)
+				buf.WriteString(indent + "}\n")
+			}
+		case field.IsList():
+			list := m.Get(field).List()
+			for j := 0; j < list.Len(); j++ {
+				writeTextValue(buf, field, name, list.Get(j), indent)
+			}
+		default:
+			writeTextValue(buf, field, name, m.Get(field), indent)
+		}
+	}
+}
+
+func writeTextValue(buf *bytes.Buffer, field protoreflect.FieldDescriptor, name string, v protoreflect.Value, indent string) {
+	if field.Message() == nil {
+		fmt.Fprintf(buf, "%s%s: %s\n", indent, name, textScalar(field, v))
+		return
+	}
+
+	var nested bytes.Buffer
+	writeTextFields(&nested, v.Message(), indent+INDENT)
+	if nested.Len() == 0 {
+		fmt.Fprintf(buf, "%s%s: {}\n", indent, name)
+		return
+	}
+	fmt.Fprintf(buf, "%s%s: {\n", indent, name)
+	buf.Write(nested.Bytes())
+	buf.WriteString(indent + "}\n")
+}
+
+// textScalar returns text of a scalar value, enums are written as names if they are declared
+func textScalar(field protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch field.Kind() {
+	case protoreflect.EnumKind:
+		if value := field.Enum().Values().ByNumber(v.Enum()); value != nil {
+			return string(value.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(v.Bool())
+	case protoreflect.StringKind:
+		return textQuote(v.String())
+	case protoreflect.BytesKind:
+		return textQuote(string(v.Bytes()))
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			return "nan"
+		case math.IsInf(f, 1):
+			return "inf"
+		case math.IsInf(f, -1):
+			return "-inf"
+		}
+		if field.Kind() == protoreflect.FloatKind {
+			return strconv.FormatFloat(f, 'g', -1, 32)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10)
+	}
+
+	return strconv.FormatInt(v.Int(), 10)
+}
+
+// textQuote returns a string literal of text format, control characters and invalid utf8 bytes are escaped in hex
+func textQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && n == 1:
+			fmt.Fprintf(&sb, `\x%02x`, s[i])
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r < ' ' || r == 0x7f:
+			fmt.Fprintf(&sb, `\x%02x`, r)
+		case r >= 0x80 && r <= 0x9f:
+			fmt.Fprintf(&sb, `\u%04x`, r)
+		default:
+			sb.WriteString(s[i : i+n])
+		}
+		i += n
+	}
+	sb.WriteByte('"')
+
+	return sb.String()
+}
+
+// sortedMapKeys returns keys of a map in order, so output of maps is stable
+func sortedMapKeys(m protoreflect.Map) []protoreflect.MapKey {
+	keys := make([]protoreflect.MapKey, 0, m.Len())
+	m.Range(func(key protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, key)
+		return true
+	})
+	sort.Slice(keys, func(i, j int) bool {
+		switch a, b := keys[i].Interface(), keys[j].Interface(); a.(type) {
+		case string:
+			return a.(string) < b.(string)
+		case bool:
+			return !a.(bool) && b.(bool)
+		case int32, int64:
+			return keys[i].Int() < keys[j].Int()
+		}
+		return keys[i].Uint() < keys[j].Uint()
+	})
+
+	return keys
+}
+
+// WriteText output data in protobuf text format to "./data/sheetname.txtpb"
+func (pr *ProtoSheet) WriteText() error {
+	raw, err := pr.MarshalText()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(pr.cfg.DataOutPath, strings.ToLower(pr.Name)+TextExt))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
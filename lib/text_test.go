@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/prototext"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestMarshalText(t *testing.T) {
+	sheet := newTestSheet("TEXTTEST", [][]string{
+		{"unique", "optional", "optional", "repeated", "optional", "optional"},
+		{"int32", "string", "bool", "2", "uint32", "uint32"},
+		{"ID", "Name", "IsOpen", "", "Items", "Items"},
+		{"", "", "", "", "", ""},
+		{"1", "a: b", "TRUE", "2", "7", "8"},
+		{"2", "", "", "", "", ""},
+	})
+
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.readData(sheet))
+
+	raw, err := pr.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, `items: {
+  ID: 1
+  Name: "a: b"
+  IsOpen: true
+  Items: 7
+  Items: 8
+}
+items: {
+  ID: 2
+}
+`, string(raw))
+
+	// lines in strings are escaped, map entries are sorted
+	sheet = newTestSheet("TEXTTEST", [][]string{
+		{"unique", "optional", "optional", "optional", "optional_struct", "optional"},
+		{"int32", "string", "enum:ItemQuality", "map<string, float>", "1", "double"},
+		{"ID", "Name", "Quality", "Rates", "Limit", "Max"},
+		{"", "", "", "", "", ""},
+		{"1", "a\n  b:  c\x01\"", "Rare", "b:0.1;a:2", "", "1e21"},
+	})
+	pr = newProtoRow(newTestConfig())
+	pr.enums = map[string]*Enum{"ItemQuality": newTestEnum()}
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.readData(sheet))
+
+	raw, err = pr.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, `items: {
+  ID: 1
+  Name: "a\n  b:  c\x01\""
+  Quality: Rare
+  limits: {
+    Max: 1e+21
+  }
+  Rates: {
+    key: "a"
+    value: 2
+  }
+  Rates: {
+    key: "b"
+    value: 0.1
+  }
+}
+`, string(raw))
+
+	// the same as parsed by protobuf
+	array := dynamicpb.NewMessage(pr.arrayDesc)
+	assert.NoError(t, prototext.Unmarshal(raw, array))
+	expected := dynamicpb.NewMessage(pr.arrayDesc)
+	assert.NoError(t, protov2.Unmarshal(pr.buf.Bytes(), expected))
+	assert.True(t, protov2.Equal(expected, array))
+}
+
+func TestWriteText(t *testing.T) {
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(testSheet))
+	assert.NoError(t, pr.readData(testSheet))
+
+	pr.cfg.DataOutPath = t.TempDir()
+	assert.NoError(t, pr.WriteText())
+
+	raw, err := ioutil.ReadFile(filepath.Join(pr.cfg.DataOutPath, "sampleone"+TextExt))
+	assert.NoError(t, err)
+	expected, err := pr.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, raw)
+
+	_, err = os.Stat(filepath.Join(pr.cfg.DataOutPath, "sampleone"+pr.cfg.DataOutExt))
+	assert.True(t, os.IsNotExist(err))
+}
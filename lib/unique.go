@@ -0,0 +1,111 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// UniqueKeySep separates unique attribute and name of composite key, e.g. "unique:key1" on ChapterID and StageID
+const UniqueKeySep = ":"
+
+var ErrUniqueKeyInvalid = errors.New("invalid unique key")
+
+// UniqueKey is a composite key of several columns, the tuple of their values is unique in a sheet
+type UniqueKey struct {
+	name string
+	vals []*Val // in column order
+}
+
+// rowPos is the position of a row, used to report where the duplicated value first appears
+type rowPos struct {
+	sheet string
+	row   int
+}
+
+// describe returns "row 5", or "sheet NAME row 5" if the row is in another sheet of cur
+func (pos rowPos) describe(cur rowPos) string {
+	if pos.sheet != cur.sheet {
+		return fmt.Sprintf("sheet %s row %d", pos.sheet, pos.row)
+	}
+
+	return fmt.Sprintf("row %d", pos.row)
+}
+
+// splitUniqueKey split attribute like "unique:key1" into "unique" and key name
+func splitUniqueKey(headType string) (string, string) {
+	parts := strings.SplitN(headType, UniqueKeySep, 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) != Unique {
+		return headType, ""
+	}
+
+	return Unique, strings.TrimSpace(parts[1])
+}
+
+// checkDup record value with its position, returns the previous position and false if value exists
+func checkDup(m map[string]map[string]rowPos, name, value string, pos rowPos) (rowPos, bool) {
+	if _, ok := m[name]; !ok {
+		m[name] = make(map[string]rowPos, 256)
+	}
+
+	if prev, ok := m[name][value]; ok {
+		return prev, false
+	}
+	m[name][value] = pos
+
+	return pos, true
+}
+
+// updateUniqueKeys group columns of composite keys in current sheet
+func (pr *ProtoSheet) updateUniqueKeys() {
+	pr.uniqueKeys = groupUniqueKeys(pr.vars)
+}
+
+// groupUniqueKeys returns composite keys of vars, in the order of their first columns
+func groupUniqueKeys(vars []*Val) []*UniqueKey {
+	var uniqueKeys []*UniqueKey
+	keys := make(map[string]*UniqueKey)
+	for _, val := range vars {
+		if val.uniqueKey == "" || val.colIdx < 0 {
+			continue
+		}
+		key, ok := keys[val.uniqueKey]
+		if !ok {
+			key = &UniqueKey{name: val.uniqueKey}
+			keys[val.uniqueKey] = key
+			uniqueKeys = append(uniqueKeys, key)
+		}
+		key.vals = append(key.vals, val)
+	}
+
+	return uniqueKeys
+}
+
+// checkUniqueKeys check if tuples of composite keys in a row have appeared in previous rows
+func (pr *ProtoSheet) checkUniqueKeys(row []string) error {
+	for _, key := range pr.uniqueKeys {
+		values := make([]string, 0, len(key.vals))
+		pairs := make([]string, 0, len(key.vals))
+		for _, val := range key.vals {
+			var cell string
+			if val.colIdx < len(row) {
+				cell = strings.TrimSpace(row[val.colIdx])
+			}
+			if cell == "" {
+				return &SheetError{Col: val.colIdx + 1, Field: val.name, Err: fmt.Errorf("%w, column of unique key %s", ErrRequiredFieldEmpty, key.name)}
+			}
+			values = append(values, cell)
+			pairs = append(pairs, val.name+"="+cell)
+		}
+
+		pr.mutex.Lock()
+		pos, ok := checkDup(pr.keyMap, key.name, strings.Join(values, "\x00"), pr.curRow)
+		pr.mutex.Unlock()
+		if !ok {
+			return &SheetError{Col: key.vals[0].colIdx + 1, Field: key.name,
+				Err: fmt.Errorf("%w (%s), first in %s", ErrDuplicateUnique, strings.Join(pairs, ", "), pos.describe(pr.curRow))}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package lib
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitUniqueKey(t *testing.T) {
+	attr, key := splitUniqueKey("unique:key1")
+	assert.Equal(t, Unique, attr)
+	assert.Equal(t, "key1", key)
+
+	attr, key = splitUniqueKey("unique")
+	assert.Equal(t, Unique, attr)
+	assert.Empty(t, key)
+
+	attr, key = splitUniqueKey("optional")
+	assert.Equal(t, Opt, attr)
+	assert.Empty(t, key)
+}
+
+func TestUniqueKeys(t *testing.T) {
+	sheet := newTestSheet("STAGETEST", [][]string{
+		{"unique:key1", "unique:key1", "optional"},
+		{"uint32", "uint32", "string"},
+		{"ChapterID", "StageID", "Name"},
+		{"", "", ""},
+		{"1", "1", "a"},
+		{"1", "2", "b"},
+		{"2", "1", "c"},
+	})
+
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(sheet))
+	assert.NoError(t, pr.readData(sheet))
+	assert.Len(t, pr.uniqueKeys, 1)
+	assert.Len(t, pr.uniqueKeys[0].vals, 2)
+
+	items, err := pr.decodeItems()
+	assert.NoError(t, err)
+	assert.Len(t, items, 3)
+
+	// another sheet of the same message shares keys
+	sheet2 := newTestSheet("STAGETEST2", [][]string{
+		{"unique:key1", "unique:key1", "optional"},
+		{"uint32", "uint32", "string"},
+		{"ChapterID", "StageID", "Name"},
+		{"", "", ""},
+		{"3", "1", "d"},
+		{"1", "2", "e"},
+	})
+	assert.NoError(t, pr.updateHeads(sheet2))
+	err = pr.readData(sheet2)
+	assert.True(t, errors.Is(err, ErrDuplicateUnique))
+	assert.EqualError(t, err, "row 6, col A, field key1: duplicate unique value (ChapterID=1, StageID=2), first in sheet STAGETEST row 6")
+}
+
+func TestUniqueKeyErrors(t *testing.T) {
+	sheet := newTestSheet("STAGETEST", [][]string{
+		{"unique:key1", "unique:key1"},
+		{"uint32", "uint32"},
+		{"ChapterID", "StageID"},
+		{"", ""},
+		{"1", ""},
+	})
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(sheet))
+	err := pr.readData(sheet)
+	assert.True(t, errors.Is(err, ErrRequiredFieldEmpty))
+
+	pr = newProtoRow(newTestConfig())
+	err = pr.updateHeads(newTestSheet("STAGETEST", [][]string{
+		{"unique", "optional_struct", "unique:key1"},
+		{"uint32", "1", "uint32"},
+		{"ID", "Limit", "Count"},
+		{"", "", ""},
+	}))
+	assert.True(t, errors.Is(err, ErrUniqueKeyInvalid))
+}
+
+func TestDuplicateUniqueRows(t *testing.T) {
+	sheet := newTestSheet("UNIQUETEST", [][]string{
+		{"unique", "optional"},
+		{"uint32", "string"},
+		{"ID", "Name"},
+		{"", ""},
+		{"1", "a"},
+		{"2", "b"},
+		{"1", "c"},
+	})
+	pr := newProtoRow(newTestConfig())
+	assert.NoError(t, pr.updateHeads(sheet))
+	err := pr.readData(sheet)
+	assert.True(t, errors.Is(err, ErrDuplicateUnique))
+	assert.EqualError(t, err, "row 7, col A, field ID: duplicate unique value 1, first in row 5")
+}
@@ -3,25 +3,23 @@ package lib
 import (
 	"crypto/md5"
 	"io"
-	"log"
 	"os"
-	"path/filepath"
 	"strings"
 )
 
-func getFileMD5(path string) []byte {
+func getFileMD5(path string) ([]byte, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer file.Close()
 
 	hash := md5.New()
 	if _, err := io.Copy(hash, file); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return hash.Sum(nil)[:]
+	return hash.Sum(nil)[:], nil
 }
 
 // IsTitleValid check if the first letter of a xlsx title is uppercase.
@@ -33,51 +31,66 @@ func IsTitleValid(title string) bool {
 	return strings.Title(title) == title
 }
 
-// IsXlsxChanged check if file has the same hash value as before
-func IsXlsxChanged(filename string) bool {
+// IsXlsxChanged check if file has the same hash value as before, returns error if the file can not be read
+func (cv *Converter) IsXlsxChanged(filename string) (bool, error) {
+	cacher := cv.cacher
 	if cacher == nil {
-		return true
+		return true, nil
 	}
 
-	// Add support for multi files
+	// Add support for multi files, all of them are hashed so the cache is up to date
 	if filenames := strings.Split(filename, "|"); len(filenames) > 1 {
 		changed := false
 		for _, fn := range filenames {
-			changed = changed || IsXlsxChanged(fn)
+			fnChanged, err := cv.IsXlsxChanged(fn)
+			if err != nil {
+				return true, err
+			}
+			changed = changed || fnChanged
 		}
-		return changed
+		return changed, nil
+	}
+
+	// let ReadSheet report the missing file
+	if !cv.IsSheetExists(filename) {
+		return true, nil
 	}
 
-	fname := filepath.Join(cfg.XlsxPath, filename+cfg.XlsxExt)
+	fHash, err := getFileMD5(cv.cfg.sourcePath(filename))
+	if err != nil {
+		return true, err
+	}
 
 	cacher.mutex.Lock()
 	defer cacher.mutex.Unlock()
 
 	if fInfo, ok := cacher.XlsxInfos[filename]; ok {
-		fHash := getFileMD5(fname)
 		if string(fHash) == string(fInfo.MD5) {
+			// file may be checked more than once, e.g. it contains both enum and other sheets
+			if fInfo.State == Updated || fInfo.State == New {
+				return true, nil
+			}
 			fInfo.State = Remained
-			return false
+			return false, nil
 		}
 
 		fInfo.MD5 = fHash
 		fInfo.State = Updated
-		return true
+		return true, nil
 	}
 
 	cacher.XlsxInfos[filename] = &DataInfo{
 		Name:  filename,
-		MD5:   getFileMD5(fname),
+		MD5:   fHash,
 		State: New,
 	}
 
-	return true
+	return true, nil
 }
 
 // IsSheetExists check if file exist in xlsx folder
-func IsSheetExists(xlsxName string) bool {
-	xlsxFullName := filepath.Join(cfg.XlsxPath, xlsxName+cfg.XlsxExt)
-	if _, err := os.Stat(xlsxFullName); err == nil {
+func (cv *Converter) IsSheetExists(xlsxName string) bool {
+	if _, err := os.Stat(cv.cfg.sourcePath(xlsxName)); err == nil {
 		return true
 	}
 
@@ -85,7 +98,8 @@ func IsSheetExists(xlsxName string) bool {
 }
 
 // IsProtoChanged check and update previous proto info
-func IsProtoChanged(ps *ProtoSheet) bool {
+func (cv *Converter) IsProtoChanged(ps *ProtoSheet) bool {
+	cacher := cv.cacher
 	if cacher == nil {
 		return true
 	}
@@ -93,7 +107,7 @@ func IsProtoChanged(ps *ProtoSheet) bool {
 	cacher.mutex.Lock()
 	defer cacher.mutex.Unlock()
 
-	if info, ok := cacher.ProtoInfos[ps.Name]; ok {
+	if info, ok := cacher.ProtoInfos[ps.outputName()]; ok {
 		if string(info.MD5) == string(ps.protoHash) {
 			info.State = Remained
 			return false
@@ -104,8 +118,8 @@ func IsProtoChanged(ps *ProtoSheet) bool {
 		return true
 	}
 
-	cacher.ProtoInfos[ps.Name] = &DataInfo{
-		Name:  ps.Name,
+	cacher.ProtoInfos[ps.outputName()] = &DataInfo{
+		Name:  ps.outputName(),
 		MD5:   ps.protoHash,
 		State: New,
 	}
@@ -113,7 +127,39 @@ func IsProtoChanged(ps *ProtoSheet) bool {
 	return true
 }
 
-func IsDataChanged(ps *ProtoSheet) bool {
+// IsLoaderChanged check and update previous loader info, loaders are generated from more than proto
+func (cv *Converter) IsLoaderChanged(ps *ProtoSheet) bool {
+	cacher := cv.cacher
+	if cacher == nil {
+		return true
+	}
+
+	cacher.mutex.Lock()
+	defer cacher.mutex.Unlock()
+
+	loaderHash := ps.loaderHash()
+	if info, ok := cacher.LoaderInfos[ps.outputName()]; ok {
+		if string(info.MD5) == string(loaderHash) {
+			info.State = Remained
+			return false
+		}
+
+		info.MD5 = loaderHash
+		info.State = Updated
+		return true
+	}
+
+	cacher.LoaderInfos[ps.outputName()] = &DataInfo{
+		Name:  ps.outputName(),
+		MD5:   loaderHash,
+		State: New,
+	}
+
+	return true
+}
+
+func (cv *Converter) IsDataChanged(ps *ProtoSheet) bool {
+	cacher := cv.cacher
 	if cacher == nil {
 		return true
 	}
@@ -121,7 +167,7 @@ func IsDataChanged(ps *ProtoSheet) bool {
 	cacher.mutex.Lock()
 	defer cacher.mutex.Unlock()
 
-	if info, ok := cacher.DataInfos[ps.Name]; ok {
+	if info, ok := cacher.DataInfos[ps.outputName()]; ok {
 		if string(info.MD5) == string(ps.dataHash) {
 			info.State = Remained
 			return false
@@ -132,8 +178,8 @@ func IsDataChanged(ps *ProtoSheet) bool {
 		return true
 	}
 
-	cacher.DataInfos[ps.Name] = &DataInfo{
-		Name:  ps.Name,
+	cacher.DataInfos[ps.outputName()] = &DataInfo{
+		Name:  ps.outputName(),
 		MD5:   ps.dataHash,
 		State: New,
 	}
@@ -8,8 +8,12 @@ import (
 )
 
 func TestGetFileMD5(t *testing.T) {
-	md5 := getFileMD5("../test/md5test")
+	md5, err := getFileMD5("../test/md5test")
+	assert.NoError(t, err)
 	assert.Equal(t, "b11e08322cbeae46b006005067623264", hex.EncodeToString(md5))
+
+	_, err = getFileMD5("../test/notexist")
+	assert.Error(t, err)
 }
 
 func TestIsTitleValid(t *testing.T) {
@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchDelay is the time to wait for more file events before converting
+// Excel writes and renames several temp files when a workbook is saved
+var WatchDelay = 500 * time.Millisecond
+
+// Watch convert changed sheets once xlsx files or xlsx*.config files in xlsx path change, until stop is closed
+// Only outdated sheets are converted, as cache is always on in watch mode
+// onRun is called with the result of each run, including the first one when watch starts
+func (cv *Converter) Watch(opts Options, stop <-chan struct{}, onRun func(*Report, error)) error {
+	opts.UseCache = true
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(cv.cfg.XlsxPath); err != nil {
+		return err
+	}
+
+	onRun(cv.Run(opts))
+
+	var timer <-chan time.Time
+	var configChanged bool
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			name, err := filepath.Rel(cv.cfg.XlsxPath, event.Name)
+			if err != nil || isTempXlsx(name) {
+				continue
+			}
+			if isConfig, _ := filepath.Match(cv.cfg.ConfigRegExp, name); isConfig {
+				configChanged = true
+			} else if !cv.isXlsxInConfig(name) {
+				continue
+			}
+
+			timer = time.After(WatchDelay) // debounce, wait until files are stable
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Printf("<Warning> watch %s fail, %v\n", cv.cfg.XlsxPath, err)
+		case <-timer:
+			timer = nil
+
+			if configChanged {
+				configChanged = false
+				if err := cv.LoadSheetConfigs(); err != nil {
+					onRun(new(Report), err)
+					continue
+				}
+			}
+
+			onRun(cv.Run(opts))
+		}
+	}
+}
+
+// isTempXlsx check if a file is the lock file of a workbook opened in Excel or LibreOffice
+func isTempXlsx(name string) bool {
+	base := filepath.Base(name)
+	return strings.HasPrefix(base, "~$") || strings.HasPrefix(base, ".~lock.")
+}
+
+// isXlsxInConfig check if a file is listed in xlsx*.config
+func (cv *Converter) isXlsxInConfig(name string) bool {
+	for _, fileMap := range []map[string][]string{cv.sheetFileMap, cv.enumFileMap} {
+		for filename := range fileMap {
+			for _, fn := range strings.Split(filename, "|") {
+				if filepath.Clean(cv.cfg.sourceFile(fn)) == name {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
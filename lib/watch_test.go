@@ -0,0 +1,72 @@
+package lib
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTempXlsx(t *testing.T) {
+	assert.True(t, isTempXlsx("~$Sample.xlsx"))
+	assert.True(t, isTempXlsx("sub/.~lock.Sample.xlsx#"))
+	assert.False(t, isTempXlsx("Sample.xlsx"))
+}
+
+func TestWatch(t *testing.T) {
+	WatchDelay = 50 * time.Millisecond
+
+	cfg := newTempConfig(t)
+	cfg.XlsxPath = t.TempDir()
+
+	raw, err := ioutil.ReadFile("../test/Sample.xlsx")
+	assert.NoError(t, err)
+	xlsxFile := filepath.Join(cfg.XlsxPath, "Sample.xlsx")
+	assert.NoError(t, ioutil.WriteFile(xlsxFile, raw, 0644))
+
+	cv := newConverter(cfg)
+	cv.sheetFileMap = map[string][]string{"Sample.xlsx": {"SAMPLEONE"}}
+
+	reports := make(chan *Report, 10)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- cv.Watch(Options{}, stop, func(report *Report, err error) {
+			assert.NoError(t, err)
+			reports <- report
+		})
+	}()
+
+	waitReport := func() *Report {
+		select {
+		case report := <-reports:
+			return report
+		case <-time.After(2 * time.Second):
+			return nil
+		}
+	}
+
+	// first run when watch starts
+	report := waitReport()
+	assert.Equal(t, []string{"SAMPLEONE"}, report.Sheets)
+
+	// lock files of Excel and files not in config are ignored
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cfg.XlsxPath, "~$Sample.xlsx"), []byte("lock"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cfg.XlsxPath, "Other.xlsx"), []byte("other"), 0644))
+	select {
+	case <-reports:
+		t.Error("unexpected run")
+	case <-time.After(5 * WatchDelay):
+	}
+
+	// saved without changes, nothing is converted
+	assert.NoError(t, ioutil.WriteFile(xlsxFile, raw, 0644))
+	report = waitReport()
+	assert.NotNil(t, report)
+	assert.Empty(t, report.Sheets)
+
+	close(stop)
+	assert.NoError(t, <-done)
+}
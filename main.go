@@ -2,13 +2,135 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
 
 	"github.com/cittie/xlsx2pb/lib"
 )
 
+const usage = `Usage: xlsx2pb [command] [flags]
+
+Commands:
+  build            convert changed sheets to proto and data files (default)
+  watch            convert changed sheets whenever xlsx files are saved
+  validate         read and check all sheets without writing any file
+  diff             show files which would change compared with the cache
+  decode <sheet>   print data file of a sheet as text, -target for a target
+  clean            remove generated files and the cache
+
+Use "xlsx2pb [command] -h" for flags of a command.
+`
+
 func main() {
-	var useCache = flag.Bool("cache", true, "Use cache for current xlsx")
-	var useGoroutine = flag.Bool("goroutine", true, "Use goroutine for faster handling")
+	cmd, args := "build", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	var cfgFile = fs.String("config", lib.DefaultConfigFile, "Path of config file")
+
+	var opts lib.Options
+	var target string
+	switch cmd {
+	case "build", "watch":
+		if cmd == "build" {
+			fs.BoolVar(&opts.UseCache, "cache", true, "Use cache for current xlsx")
+		}
+		fs.BoolVar(&opts.StrictCompat, "strict-compat", false, "Fail sheets whose new proto breaks clients using the old one")
+		fallthrough
+	case "validate", "diff":
+		fs.BoolVar(&opts.UseGoroutine, "goroutine", true, "Use goroutine for faster handling")
+		fs.IntVar(&opts.Workers, "workers", 0, "Count of sheets handled at the same time, 0 for the count of CPUs")
+		fs.BoolVar(&opts.FailFast, "failfast", false, "Stop at the first failed sheet")
+	case "decode":
+		fs.StringVar(&target, "target", "", "Export target of the data file, required if targets are set in config")
+	case "clean":
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	fs.Parse(args)
+
+	cfg, err := lib.LoadConfig(*cfgFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	cv, err := lib.NewConverter(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	switch cmd {
+	case "build":
+		err = build(cv, opts)
+	case "watch":
+		err = watch(cv, opts)
+	case "validate":
+		// all sheets are checked, incompatible proto changes are errors
+		opts.UseCache, opts.StrictCompat, opts.DryRun = false, true, true
+		err = build(cv, opts)
+	case "diff":
+		opts.UseCache, opts.DryRun = true, true
+		err = diff(cv, opts)
+	case "decode":
+		if fs.NArg() != 1 {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		err = cv.Decode(fs.Arg(0), target, os.Stdout)
+	case "clean":
+		err = cv.Clean()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func build(cv *lib.Converter, opts lib.Options) error {
+	report, err := cv.Run(opts)
+	fmt.Print(report.Summary())
+
+	return err
+}
+
+func watch(cv *lib.Converter, opts lib.Options) error {
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		close(stop)
+	}()
+
+	return cv.Watch(opts, stop, func(report *lib.Report, err error) {
+		fmt.Print(report.Summary())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		fmt.Printf("%s watching %s, press Ctrl+C to stop\n", time.Now().Format("15:04:05"), cv.Config().XlsxPath)
+	})
+}
+
+func diff(cv *lib.Converter, opts lib.Options) error {
+	report, err := cv.Run(opts)
+	fmt.Print(report.Summary())
+
+	changes := cv.Changes()
+	if len(changes) == 0 {
+		fmt.Println("no changes")
+	}
+	for _, change := range changes {
+		fmt.Printf("%-8s %-5s %s\n", change.State, change.Kind, change.Name)
+	}
 
-	lib.Run(*useCache, *useGoroutine)
+	return err
 }